@@ -0,0 +1,69 @@
+package unixtransport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DialURI is a convenience function that calls [DialURIConfig] with a default
+// [net.Dialer].
+func DialURI(ctx context.Context, uri string) (net.Conn, error) {
+	return DialURIConfig(ctx, uri, net.Dialer{})
+}
+
+// DialURIConfig is the client-side counterpart to [ListenURIConfig]. It parses
+// `uri` into a network and address using [ParseURI], and then dials that
+// network and address using the provided [net.Dialer]. So "tcp://host:80"
+// dials TCP, "unix:///tmp/my.sock" and "unix://@name" dial Unix sockets, and
+// URIs without a scheme, like "localhost:8080", dial TCP.
+//
+// URIs with a "+unix" scheme, like "http+unix:///tmp/my.sock:/request/path",
+// dial the Unix socket that a transport configured via [Register] would dial,
+// see [SplitURL]. Logical socket names are resolved with [DefaultResolver].
+// Note that this only establishes the connection; it doesn't speak HTTP.
+// Networks with a "tls+" prefix, like "tls+unix", aren't supported, since the
+// connection would be plaintext.
+func DialURIConfig(ctx context.Context, uri string, dialer net.Dialer) (net.Conn, error) {
+	network, address, _, err := parseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, isTLS := splitTLSNetwork(network); isTLS {
+		return nil, fmt.Errorf("can't dial %s, TLS networks are only supported for listening", network)
+	}
+
+	switch {
+	case network == "fd" || network == "systemd":
+		return nil, fmt.Errorf("can't dial inherited socket %s", uri)
+
+	case strings.HasSuffix(network, "+unix"):
+		u, err := url.Parse(strings.TrimSpace(uri))
+		if err != nil {
+			return nil, fmt.Errorf("parse URI: %w", err)
+		}
+
+		uu, err := parseUnixURL(u)
+		if err != nil {
+			return nil, err
+		}
+
+		network, address = "unix", uu.socketPath
+
+		if isSocketName(address) {
+			if address, err = DefaultResolver().ResolveSocket(ctx, address); err != nil {
+				return nil, fmt.Errorf("resolve socket: %w", err)
+			}
+		}
+	}
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,132 @@
+package unixtransport_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestDialURI(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	tcp := serveGreeting(t, "tcp", "127.0.0.1:0")
+	_, port, _ := net.SplitHostPort(tcp.Addr().String())
+
+	socket := filepath.Join(t.TempDir(), "sock")
+	serveGreeting(t, "unix", socket)
+
+	uris := []string{
+		"tcp://" + tcp.Addr().String(),
+		"localhost:" + port,
+		"unix://" + socket,
+		"http+unix://" + socket + ":/request/path",
+		newURL(t, "https+unix", socket, "/x"),
+	}
+
+	if runtime.GOOS == "linux" {
+		name := abstractName(t)
+		serveGreeting(t, "unix", "@"+name)
+		uris = append(uris, "unix://@"+name, "http+unix://@"+name+":/request/path")
+	}
+
+	for _, uri := range uris {
+		t.Run(uri, func(t *testing.T) {
+			conn, err := unixtransport.DialURI(ctx, uri)
+			if err != nil {
+				t.Fatalf("DialURI: %v", err)
+			}
+			defer conn.Close()
+
+			buf, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if want, have := "hello", string(buf); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestDialURIConfig(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "sock")
+	serveGreeting(t, "unix", socket)
+
+	var seen []string
+	dialer := net.Dialer{Control: func(network, address string, _ syscall.RawConn) error {
+		seen = append(seen, network+" "+address)
+		return nil
+	}}
+
+	conn, err := unixtransport.DialURIConfig(context.Background(), "http+unix://"+socket+":/foo", dialer)
+	if err != nil {
+		t.Fatalf("DialURIConfig: %v", err)
+	}
+	conn.Close()
+
+	if want, have := "unix "+socket, strings.Join(seen, ","); want != have {
+		t.Errorf("seen: want %q, have %q", want, have)
+	}
+}
+
+func TestDialURIErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// TLS networks should fail, even if there's something listening.
+	socket := filepath.Join(t.TempDir(), "sock")
+	serveGreeting(t, "unix", socket)
+
+	for _, uri := range []string{
+		"",
+		"fd://3",
+		"systemd://",
+		"http+unix://",
+		"unix://" + filepath.Join(t.TempDir(), "missing"),
+		"tls+unix://" + socket,
+		"tls+unix://" + socket + ":x",
+		"tls+tcp://localhost:443",
+	} {
+		if conn, err := unixtransport.DialURI(ctx, uri); err == nil {
+			conn.Close()
+			t.Errorf("DialURI(%q): want error, have none", uri)
+		}
+	}
+}
+
+// serveGreeting listens on the network and address, and writes "hello" to, and
+// then closes, every accepted connection.
+func serveGreeting(t *testing.T, network, address string) net.Listener {
+	t.Helper()
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("hello"))
+			conn.Close()
+		}
+	}()
+
+	return ln
+}
@@ -1,7 +1,9 @@
 package unixtransport_test
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/peterbourgon/unixtransport"
 )
@@ -38,3 +40,30 @@ func ExampleRegister() {
 	// Make a GET request to the HTTP server listening at /tmp/my.sock.
 	c.Get("https+unix:///tmp/my.sock")
 }
+
+func ExampleNewTransport() {
+	// Create a client that only speaks "http+unix" and "https+unix", without
+	// modifying the base transport.
+	c := &http.Client{
+		Transport: unixtransport.NewTransport(&http.Transport{
+			// ...
+		}),
+	}
+
+	// Make a GET request to the HTTP server listening at /tmp/my.sock.
+	c.Get("http+unix:///tmp/my.sock:/users/123")
+}
+
+func ExampleNewURL() {
+	u, _ := unixtransport.NewURL("http+unix", "/run/app:v2/http.sock", "/users/123", url.Values{"q": {"abc"}})
+	fmt.Println(u)
+
+	socketPath, requestPath, _ := unixtransport.SplitURL(u)
+	fmt.Println(socketPath)
+	fmt.Println(requestPath)
+
+	// Output:
+	// http+unix:///run/app%3Av2/http.sock:/users/123?q=abc
+	// /run/app:v2/http.sock
+	// /users/123
+}
@@ -0,0 +1,120 @@
+package unixtransport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HostSocketFunc maps an address in host:port form, as dialed by an
+// [http.Transport], to the path of the Unix socket that serves it. It returns
+// false if the address should be dialed normally.
+type HostSocketFunc func(address string) (socketPath string, ok bool)
+
+// HostSocketMap returns a HostSocketFunc backed by the given map. Keys are
+// either host:port addresses, which match only that port, or bare hosts, which
+// match any port. Values are socket paths. Keys are case-insensitive.
+func HostSocketMap(m map[string]string) HostSocketFunc {
+	normalized := make(map[string]string, len(m))
+	for k, v := range m {
+		normalized[strings.ToLower(k)] = v
+	}
+
+	return func(address string) (string, bool) {
+		address = strings.ToLower(address)
+
+		if socketPath, ok := normalized[address]; ok {
+			return socketPath, true
+		}
+
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			if socketPath, ok := normalized[host]; ok {
+				return socketPath, true
+			}
+		}
+
+		return "", false
+	}
+}
+
+// NewHostTransport returns a clone of the base transport, which dials the Unix
+// socket given by sockets, rather than the network address, for every request
+// address which sockets maps. This allows ordinary URLs like
+// http://api.internal/path to be served over Unix sockets.
+//
+// Request URLs aren't modified, so the Host header is the original host, and
+// HTTPS requests use the original host for TLS server name indication and
+// certificate verification. Requests to mapped addresses bypass any configured
+// Proxy. Requests to all other addresses work as normal.
+func NewHostTransport(base *http.Transport, sockets HostSocketFunc) *http.Transport {
+	copy := base.Clone()
+
+	copy.Dial = nil    //lint:ignore SA1019 yes, it's deprecated, that's the point
+	copy.DialTLS = nil //lint:ignore SA1019 yes, it's deprecated, that's the point
+
+	if copy.DialContext == nil {
+		copy.DialContext = defaultDialContextFunc
+	}
+	copy.DialContext = hostDialContextAdapter(sockets, copy.DialContext)
+
+	if copy.DialTLSContext != nil {
+		copy.DialTLSContext = hostDialContextAdapter(sockets, copy.DialTLSContext)
+	}
+
+	if proxy := copy.Proxy; proxy != nil {
+		copy.Proxy = func(req *http.Request) (*url.URL, error) {
+			if _, ok := sockets(canonicalAddr(req.URL)); ok {
+				return nil, nil // Proxy doesn't support Unix sockets, so skip it
+			}
+			return proxy(req)
+		}
+	}
+
+	return copy
+}
+
+// NewClient returns an HTTP client which serves requests to addresses mapped
+// by sockets over the corresponding Unix sockets, see [NewHostTransport], and
+// which also supports the "http+unix" and "https+unix" schemes, see
+// [Register]. The client's transport is based on a clone of the
+// [http.DefaultTransport], if it's an [http.Transport].
+func NewClient(sockets HostSocketFunc) *http.Client {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		base = &http.Transport{}
+	}
+
+	t := NewHostTransport(base, sockets)
+	Register(t)
+
+	return &http.Client{Transport: t}
+}
+
+// hostDialContextAdapter decorates the provided DialContext function by
+// dialing the Unix socket mapped by sockets, if there is one, instead of the
+// provided address.
+func hostDialContextAdapter(sockets HostSocketFunc, next dialContextFunc) dialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if socketPath, ok := sockets(address); ok {
+			network, address = "unix", socketPath
+		}
+		return next(ctx, network, address)
+	}
+}
+
+// canonicalAddr returns the host:port address that an http.Transport would
+// dial for the URL.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
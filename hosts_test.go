@@ -0,0 +1,138 @@
+package unixtransport_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestHostSocketMap(t *testing.T) {
+	t.Parallel()
+
+	sockets := unixtransport.HostSocketMap(map[string]string{
+		"api.internal":      "/tmp/api.sock",
+		"API.internal:8080": "/tmp/api-8080.sock",
+		"::1":               "/tmp/ipv6.sock",
+	})
+
+	for _, testcase := range []struct {
+		address    string
+		socketPath string
+		ok         bool
+	}{
+		{"api.internal:80", "/tmp/api.sock", true},
+		{"api.internal:443", "/tmp/api.sock", true},
+		{"Api.Internal:8080", "/tmp/api-8080.sock", true},
+		{"[::1]:80", "/tmp/ipv6.sock", true},
+		{"other.internal:80", "", false},
+		{"api.internal.other:80", "", false},
+	} {
+		socketPath, ok := sockets(testcase.address)
+		if socketPath != testcase.socketPath || ok != testcase.ok {
+			t.Errorf("%s: want %q %v, have %q %v", testcase.address, testcase.socketPath, testcase.ok, socketPath, ok)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, r.Host, r.URL.Path) })
+
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	{
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		server := httptest.NewUnstartedServer(handler)
+		server.Listener = ln
+		server.Start()
+		t.Cleanup(func() { server.Close() })
+	}
+
+	tcpServer := httptest.NewServer(handler)
+	t.Cleanup(func() { tcpServer.Close() })
+
+	client := unixtransport.NewClient(unixtransport.HostSocketMap(map[string]string{
+		"api.internal": socket,
+	}))
+
+	for _, testcase := range []struct {
+		rawurl string
+		want   string
+	}{
+		{"http://api.internal/foo", "api.internal /foo"},
+		{"http://api.internal:8080/bar", "api.internal:8080 /bar"},
+		{tcpServer.URL + "/qux", tcpServer.Listener.Addr().String() + " /qux"},
+	} {
+		if want, have := testcase.want, get(t, client, testcase.rawurl); want != have {
+			t.Errorf("%s: want %q, have %q", testcase.rawurl, want, have)
+		}
+	}
+
+	// The client should also support the http+unix scheme.
+	rawurl := "http+unix://" + socket + ":/baz"
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawurl, err)
+	}
+	resp.Body.Close()
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Errorf("GET %s: want %d, have %d", rawurl, want, have)
+	}
+}
+
+func TestNewHostTransportTLS(t *testing.T) {
+	t.Parallel()
+
+	// The httptest.Server TLS certificate is valid for "example.com", so that's
+	// the host we map to the socket. Note there's no explicit ServerName in the
+	// client TLS config.
+	var (
+		socket          = filepath.Join(t.TempDir(), "tls.sock")
+		tlsClientConfig = &tls.Config{}
+	)
+	{
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, r.Host, r.TLS.ServerName) })
+		server := httptest.NewUnstartedServer(handler)
+		server.Listener = ln
+		server.StartTLS()
+		t.Cleanup(func() { server.Close() })
+
+		certpool := x509.NewCertPool()
+		certpool.AddCert(server.Certificate())
+		tlsClientConfig.RootCAs = certpool
+	}
+
+	transport := unixtransport.NewHostTransport(&http.Transport{
+		TLSClientConfig: tlsClientConfig,
+		Proxy: func(*http.Request) (*url.URL, error) {
+			return nil, fmt.Errorf("proxy shouldn't be used")
+		},
+	}, unixtransport.HostSocketMap(map[string]string{
+		"example.com": socket,
+	}))
+	client := &http.Client{Transport: transport}
+
+	rawurl := "https://example.com/foo"
+	if want, have := "example.com example.com", get(t, client, rawurl); want != have {
+		t.Errorf("%s: want %q, have %q", rawurl, want, have)
+	}
+}
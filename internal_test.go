@@ -0,0 +1,75 @@
+package unixtransport
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServerNameTransports(t *testing.T) {
+	t.Parallel()
+
+	s := &serverNameTransports{base: &http.Transport{}}
+
+	first := s.get("host-0:443")
+	if want, have := "host-0", first.TLSClientConfig.ServerName; want != have {
+		t.Errorf("ServerName: want %q, have %q", want, have)
+	}
+	if s.get("host-0") != first {
+		t.Errorf("host-0: want cached transport, have a new one")
+	}
+
+	// Fill the cache, keep using host-0, and then go beyond the cap. The least
+	// recently used transport, host-1, should be evicted, and host-0 kept.
+	for i := 1; i < maxServerNameTransports; i++ {
+		s.get(fmt.Sprintf("host-%d", i))
+	}
+	s.get("host-0")
+	s.get("one-too-many")
+
+	if want, have := maxServerNameTransports, len(s.transports); want != have {
+		t.Errorf("transports: want %d, have %d", want, have)
+	}
+	if _, ok := s.transports["host-1"]; ok {
+		t.Errorf("host-1: want evicted, still cached")
+	}
+	if s.get("host-0") != first {
+		t.Errorf("host-0: want cached transport, have a new one")
+	}
+
+	s.closeIdleConnections()
+}
+
+func TestSocketFileReusedInode(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	defer ln.Close()
+
+	file, err := newSocketFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new socket at the same path can get the same inode number. Simulate
+	// that by changing the modification time of the file.
+	later := file.info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	file.remove()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("replaced socket file after remove: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package unixtransport
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+// socketFile manages the socket file of a Unix listener or packet conn. It
+// removes the file when the socket is closed, but only if the file at the
+// socket path is still the one that was created for the socket. It also
+// releases the socket lock, if there is one.
+type socketFile struct {
+	path string
+	info os.FileInfo
+	lock *socketLock // may be nil
+	keep bool        // don't remove the file
+	once sync.Once
+}
+
+// newSocketFile should be called right after the socket file at path has been
+// created. A nil lock is valid.
+func newSocketFile(path string, lock *socketLock) (*socketFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat socket: %w", err)
+	}
+
+	return &socketFile{
+		path: path,
+		info: info,
+		lock: lock,
+	}, nil
+}
+
+// remove removes the socket file, if appropriate. It should be called before
+// the socket is closed, like the net package does for Unix listeners.
+func (f *socketFile) remove() {
+	f.once.Do(func() {
+		if f.keep {
+			return
+		}
+		if info, err := os.Stat(f.path); err == nil && sameSocketFile(info, f.info) {
+			os.Remove(f.path)
+		}
+	})
+}
+
+// sameSocketFile returns true if a and b describe the same socket file. Inode
+// numbers are commonly reused right away, so a new socket file at the same path
+// can look like the old one to os.SameFile, and the modification time is
+// compared too.
+func sameSocketFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime())
+}
+
+// release releases the lock, if there is one. It should be called after the
+// socket is closed.
+func (f *socketFile) release() {
+	f.lock.release()
+}
+
+// socketListener is a Unix listener with a managed socket file.
+type socketListener struct {
+	*net.UnixListener
+	file *socketFile
+}
+
+// newSocketListener wraps a Unix listener, which must have just created its
+// socket file at path. The net package would unconditionally remove that path
+// on Close, so that's disabled. A nil lock is valid.
+func newSocketListener(ul *net.UnixListener, path string, lock *socketLock) (*socketListener, error) {
+	file, err := newSocketFile(path, lock)
+	if err != nil {
+		return nil, err
+	}
+
+	ul.SetUnlinkOnClose(false)
+
+	return &socketListener{
+		UnixListener: ul,
+		file:         file,
+	}, nil
+}
+
+// Addr returns the address of the socket file, which may differ from the
+// address the socket was bound to, see createSocket.
+func (ln *socketListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: ln.file.path, Net: ln.UnixListener.Addr().Network()}
+}
+
+// Close removes the socket file, if appropriate, closes the listener, and
+// releases the lock.
+func (ln *socketListener) Close() error {
+	ln.file.remove()
+	err := ln.UnixListener.Close()
+	ln.file.release()
+	return err
+}
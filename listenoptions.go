@@ -0,0 +1,216 @@
+package unixtransport
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// listenOptions are parsed from the query parameters of a listen URI.
+type listenOptions struct {
+	mode        os.FileMode
+	hasMode     bool
+	uid, gid    int // -1 if unset, like os.Chown
+	forceRemove bool
+	keepSocket  bool // don't remove the socket file on Close
+	lock        bool
+
+	tls          bool // network has the "tls+" prefix
+	certFile     string
+	keyFile      string
+	clientCAFile string
+}
+
+// listenOptionKeys are the query parameters understood by parseListenOptions.
+var listenOptionKeys = map[string]bool{
+	"mode":   true,
+	"owner":  true,
+	"group":  true,
+	"remove": true,
+	"unlink": true,
+	"lock":   true,
+
+	"cert":      true,
+	"key":       true,
+	"client-ca": true,
+}
+
+// parseListenOptions parses options from the query of a URI with the given
+// network and address. The network may have the "tls+" prefix. Unknown query
+// parameters are ignored.
+func parseListenOptions(network, address string, query url.Values) (listenOptions, error) {
+	options := listenOptions{uid: -1, gid: -1}
+
+	network, options.tls = splitTLSNetwork(network)
+
+	if s := query.Get("mode"); s != "" {
+		mode, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || mode&^uint64(os.ModePerm) != 0 {
+			return listenOptions{}, fmt.Errorf("invalid mode %q", s)
+		}
+		options.mode, options.hasMode = os.FileMode(mode), true
+	}
+
+	if s := query.Get("owner"); s != "" {
+		uid, err := lookupUID(s)
+		if err != nil {
+			return listenOptions{}, err
+		}
+		options.uid = uid
+	}
+
+	if s := query.Get("group"); s != "" {
+		gid, err := lookupGID(s)
+		if err != nil {
+			return listenOptions{}, err
+		}
+		options.gid = gid
+	}
+
+	switch s := query.Get("remove"); s {
+	case "", "stale":
+		options.forceRemove = false
+	case "force":
+		options.forceRemove = true
+	default:
+		return listenOptions{}, fmt.Errorf("invalid remove %q (want stale or force)", s)
+	}
+
+	if s := query.Get("unlink"); s != "" {
+		unlink, err := strconv.ParseBool(s)
+		if err != nil {
+			return listenOptions{}, fmt.Errorf("invalid unlink %q", s)
+		}
+		options.keepSocket = !unlink
+	}
+
+	if s := query.Get("lock"); s != "" {
+		lock, err := strconv.ParseBool(s)
+		if err != nil {
+			return listenOptions{}, fmt.Errorf("invalid lock %q", s)
+		}
+		options.lock = lock
+	}
+
+	if options.lock && (!isUnixNetwork(network) || isAbstractAddress(address)) {
+		return listenOptions{}, fmt.Errorf("lock is only supported for Unix sockets on the filesystem")
+	}
+
+	if options.hasPermissions() && (!isUnixNetwork(network) || isAbstractAddress(address)) {
+		return listenOptions{}, fmt.Errorf("mode, owner, and group are only supported for Unix sockets on the filesystem")
+	}
+
+	options.certFile = query.Get("cert")
+	options.keyFile = query.Get("key")
+	options.clientCAFile = query.Get("client-ca")
+
+	switch {
+	case options.tls && (options.certFile == "" || options.keyFile == ""):
+		return listenOptions{}, fmt.Errorf("tls+%s requires cert and key", network)
+	case !options.tls && (options.certFile != "" || options.keyFile != "" || options.clientCAFile != ""):
+		return listenOptions{}, fmt.Errorf("cert, key, and client-ca are only supported for tls+ networks")
+	}
+
+	return options, nil
+}
+
+func (o listenOptions) hasPermissions() bool {
+	return o.hasMode || o.uid >= 0 || o.gid >= 0
+}
+
+// applyPermissions sets the mode, owner, and group of the socket file at path,
+// if they're configured.
+func (o listenOptions) applyPermissions(path string) error {
+	if o.uid >= 0 || o.gid >= 0 {
+		if err := os.Chown(path, o.uid, o.gid); err != nil {
+			return fmt.Errorf("set socket owner: %w", err)
+		}
+	}
+
+	if o.hasMode {
+		if err := os.Chmod(path, o.mode); err != nil {
+			return fmt.Errorf("set socket mode: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// createSocket calls create to create the socket at address. If the options
+// set the mode, owner, or group of the socket file, which parseListenOptions
+// only allows for Unix sockets on the filesystem, create is instead called with
+// a path in a private temporary directory next to address, and the socket file
+// is renamed to address once those have been applied. So clients can never
+// connect to the socket while it still has the permissions from the umask. If
+// anything fails after create succeeds, the closer it returned is closed.
+func createSocket(address string, options listenOptions, create func(path string) (io.Closer, error)) error {
+	if !options.hasPermissions() {
+		_, err := create(address)
+		return err
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(address), ".sock") // mode 0700
+	if err != nil {
+		return fmt.Errorf("create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "s") // short, to fit in sun_path
+
+	c, err := create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := options.applyPermissions(path); err != nil {
+		c.Close()
+		return err
+	}
+
+	if err := os.Rename(path, address); err != nil {
+		c.Close()
+		return fmt.Errorf("move socket into place: %w", err)
+	}
+
+	return nil
+}
+
+func lookupUID(s string) (int, error) {
+	if uid, err := strconv.Atoi(s); err == nil && uid >= 0 {
+		return uid, nil
+	}
+
+	u, err := user.Lookup(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid owner: %w", err)
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid owner: %s has non-numeric user ID %s", s, u.Uid)
+	}
+
+	return uid, nil
+}
+
+func lookupGID(s string) (int, error) {
+	if gid, err := strconv.Atoi(s); err == nil && gid >= 0 {
+		return gid, nil
+	}
+
+	g, err := user.LookupGroup(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid group: %w", err)
+	}
+
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid group: %s has non-numeric group ID %s", s, g.Gid)
+	}
+
+	return gid, nil
+}
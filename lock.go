@@ -0,0 +1,108 @@
+package unixtransport
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// socketLock is an exclusive advisory lock on the file <socket>.lock, which
+// guards ownership of a Unix socket between processes. The lock file contains
+// the process ID of the owner.
+type socketLock struct {
+	path string
+	f    *os.File
+	once sync.Once
+}
+
+// errLocked is returned by tryLock if another process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+// lockSocket acquires the lock for the socket at address, or returns a
+// [*SocketInUseError] if another process holds it.
+func lockSocket(address string) (*socketLock, error) {
+	path := address + ".lock"
+
+	// The previous owner removes the lock file before releasing the lock, so
+	// we might lock a file that's no longer at the path. Retry in that case.
+	for attempt := 1; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open lock file: %w", err)
+		}
+
+		if err := tryLock(f); err != nil {
+			f.Close()
+			if errors.Is(err, errLocked) {
+				return nil, &SocketInUseError{Address: address, PID: readLockPID(path)}
+			}
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+
+		if sameFile(f, path) {
+			if err := writeLockPID(f); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("write lock file: %w", err)
+			}
+			return &socketLock{path: path, f: f}, nil
+		}
+
+		f.Close()
+
+		if attempt >= 3 {
+			return nil, fmt.Errorf("lock %s: lock file keeps changing", path)
+		}
+	}
+}
+
+// release removes the lock file, and then releases the lock. It's a no-op if
+// the lock is nil, or has already been released.
+func (l *socketLock) release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		os.Remove(l.path)
+		l.f.Close()
+	})
+}
+
+func sameFile(f *os.File, path string) bool {
+	fi1, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	fi2, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(fi1, fi2)
+}
+
+func writeLockPID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// readLockPID returns the process ID in the lock file, or 0 if it's unknown.
+func readLockPID(path string) int {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
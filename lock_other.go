@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package unixtransport
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// tryLock isn't supported on this platform.
+func tryLock(f *os.File) error {
+	return fmt.Errorf("lock files aren't supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,145 @@
+package unixtransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ListenURIs calls [ListenURIConfig] with a default [net.ListenConfig] for each
+// of the URIs, and returns a single listener that merges all of them. Its
+// Accept method returns connections from any of the underlying listeners, its
+// Addr method returns a [MultiAddr] with all of their addresses, and its Close
+// method closes all of them.
+//
+// If any URI fails, the listeners that were already constructed are closed,
+// and the error is returned.
+func ListenURIs(ctx context.Context, uris ...string) (net.Listener, error) {
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("no URIs")
+	}
+
+	listeners := make([]net.Listener, 0, len(uris))
+	for _, uri := range uris {
+		ln, err := ListenURIConfig(ctx, uri, net.ListenConfig{})
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", uri, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	return newMultiListener(listeners), nil
+}
+
+// MultiAddr is the address of a listener returned by [ListenURIs]. It contains
+// the addresses of each of the underlying listeners, in order.
+type MultiAddr []net.Addr
+
+// Network implements net.Addr, returning the networks of each address,
+// separated by commas.
+func (a MultiAddr) Network() string {
+	networks := make([]string, len(a))
+	for i, addr := range a {
+		networks[i] = addr.Network()
+	}
+	return strings.Join(networks, ",")
+}
+
+// String implements net.Addr, returning each address, separated by commas.
+func (a MultiAddr) String() string {
+	addrs := make([]string, len(a))
+	for i, addr := range a {
+		addrs[i] = addr.String()
+	}
+	return strings.Join(addrs, ",")
+}
+
+// multiListener merges the connections of several listeners.
+type multiListener struct {
+	listeners []net.Listener
+	results   chan acceptResult
+	done      chan struct{}
+	once      sync.Once
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+func newMultiListener(listeners []net.Listener) *multiListener {
+	ml := &multiListener{
+		listeners: listeners,
+		results:   make(chan acceptResult),
+		done:      make(chan struct{}),
+	}
+
+	for _, ln := range listeners {
+		go ml.acceptLoop(ln)
+	}
+
+	return ml
+}
+
+// acceptLoop forwards the results of Accept from the listener, until the
+// listener returns an error that isn't temporary, or the multiListener is
+// closed.
+func (ml *multiListener) acceptLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+
+		select {
+		case ml.results <- acceptResult{conn, err}:
+		case <-ml.done:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+
+		var temporary interface{ Temporary() bool }
+		if err != nil && !(errors.As(err, &temporary) && temporary.Temporary()) {
+			return
+		}
+	}
+}
+
+// Accept implements net.Listener.
+func (ml *multiListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-ml.results:
+		return r.conn, r.err
+	case <-ml.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener, closing all of the underlying listeners. It
+// returns the first error from any of them.
+func (ml *multiListener) Close() error {
+	var err error
+	ml.once.Do(func() {
+		close(ml.done)
+		for _, ln := range ml.listeners {
+			if cerr := ln.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// Addr implements net.Listener, returning a [MultiAddr].
+func (ml *multiListener) Addr() net.Addr {
+	addrs := make(MultiAddr, len(ml.listeners))
+	for i, ln := range ml.listeners {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
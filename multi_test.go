@@ -0,0 +1,94 @@
+package unixtransport_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestListenURIs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		socket = filepath.Join(t.TempDir(), "sock")
+	)
+
+	ln, err := unixtransport.ListenURIs(ctx, "tcp://127.0.0.1:0", "unix://"+socket)
+	if err != nil {
+		t.Fatalf("ListenURIs: %v", err)
+	}
+
+	addrs, ok := ln.Addr().(unixtransport.MultiAddr)
+	if !ok {
+		t.Fatalf("Addr: want MultiAddr, have %T", ln.Addr())
+	}
+	if want, have := 2, len(addrs); want != have {
+		t.Fatalf("Addr: want %d addresses, have %d", want, have)
+	}
+	if want, have := "tcp,unix", addrs.Network(); want != have {
+		t.Errorf("Addr network: want %q, have %q", want, have)
+	}
+	if want, have := addrs[0].String()+","+socket, addrs.String(); want != have {
+		t.Errorf("Addr string: want %q, have %q", want, have)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "hello", r.URL.Path) })
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = ln
+	server.Start()
+
+	transport := &http.Transport{}
+	unixtransport.Register(transport)
+	client := &http.Client{Transport: transport}
+
+	for _, rawurl := range []string{
+		"http://" + addrs[0].String() + "/foo",
+		"http+unix://" + socket + ":/foo",
+		"http://" + addrs[0].String() + "/foo",
+		"http+unix://" + socket + ":/foo",
+	} {
+		if want, have := "hello /foo", get(t, client, rawurl); want != have {
+			t.Errorf("%s: want %q, have %q", rawurl, want, have)
+		}
+	}
+
+	server.Close()
+	transport.CloseIdleConnections()
+
+	if _, err := net.Dial("tcp", addrs[0].String()); err == nil {
+		t.Errorf("dial TCP after Close: want error, have none")
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket file after Close: want not-exist error, have %v", err)
+	}
+}
+
+func TestListenURIsFailure(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		socket = filepath.Join(t.TempDir(), "sock")
+	)
+
+	if _, err := unixtransport.ListenURIs(ctx); err == nil {
+		t.Errorf("ListenURIs(): want error, have none")
+	}
+
+	if _, err := unixtransport.ListenURIs(ctx, "unix://"+socket, "doesnotexist://foo"); err == nil {
+		t.Fatalf("ListenURIs with invalid URI: want error, have none")
+	}
+
+	// The listener that was constructed before the failure should be closed.
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket file: want not-exist error, have %v", err)
+	}
+}
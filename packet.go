@@ -0,0 +1,112 @@
+package unixtransport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+)
+
+// ListenPacketURI is a convenience function that calls [ListenPacketURIConfig]
+// with a default [net.ListenConfig].
+func ListenPacketURI(ctx context.Context, uri string) (net.PacketConn, error) {
+	return ListenPacketURIConfig(ctx, uri, net.ListenConfig{})
+}
+
+// ListenPacketURIConfig is the packet-oriented counterpart to
+// [ListenURIConfig]. It parses `uri` into a network and address using
+// [ParseURI], then constructs a packet conn on that network and address, using
+// the provided [net.ListenConfig]. For example, "udp://:12345" listens on UDP
+// port 12345, and "unixgram:///tmp/my.sock" listens on a Unix datagram socket.
+//
+// For the "unixgram" network, the socket file is managed just like for stream
+// Unix sockets in ListenURIConfig: stale socket files are removed first, the
+// URI query parameters "remove", "mode", "owner", "group", "unlink", and "lock"
+// are supported, and the socket file is removed when the packet conn is
+// closed. The "fd" and "systemd" networks are also supported, for inherited
+// datagram sockets.
+//
+// Stream networks like "tcp" and "unix" aren't supported, see ListenURIConfig
+// instead.
+func ListenPacketURIConfig(ctx context.Context, uri string, config net.ListenConfig) (net.PacketConn, error) {
+	network, address, query, err := parseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := parseListenOptions(network, address, query)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case network == "fd" || network == "systemd":
+		f, err := inheritedFile(network, address)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		conn, err := net.FilePacketConn(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+
+		return conn, nil
+
+	case !isPacketNetwork(network):
+		return nil, fmt.Errorf("%s isn't a packet network, use ListenURI", network)
+	}
+
+	lock, err := prepareSocket(ctx, network, address, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.PacketConn
+	if err := createSocket(address, options, func(path string) (io.Closer, error) {
+		var err error
+		if conn, err = config.ListenPacket(ctx, network, path); err != nil {
+			return nil, fmt.Errorf("listen: %w", err)
+		}
+		return conn, nil
+	}); err != nil {
+		lock.release()
+		return nil, err
+	}
+
+	if uc, ok := conn.(*net.UnixConn); ok && !isAbstractAddress(address) {
+		file, err := newSocketFile(address, lock)
+		if err != nil {
+			uc.Close()
+			lock.release()
+			return nil, err
+		}
+
+		file.keep = options.keepSocket
+		conn = &socketPacketConn{UnixConn: uc, file: file}
+	}
+
+	return conn, nil
+}
+
+// socketPacketConn is a Unix datagram socket with a managed socket file.
+type socketPacketConn struct {
+	*net.UnixConn
+	file *socketFile
+}
+
+// LocalAddr returns the address of the socket file, which may differ from the
+// address the socket was bound to, see createSocket.
+func (c *socketPacketConn) LocalAddr() net.Addr {
+	return &net.UnixAddr{Name: c.file.path, Net: c.UnixConn.LocalAddr().Network()}
+}
+
+// Close removes the socket file, if appropriate, closes the conn, and releases
+// the lock.
+func (c *socketPacketConn) Close() error {
+	c.file.remove()
+	err := c.UnixConn.Close()
+	c.file.release()
+	return err
+}
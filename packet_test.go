@@ -0,0 +1,106 @@
+package unixtransport_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestListenPacketURI(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("udp", func(t *testing.T) {
+		conn, err := unixtransport.ListenPacketURI(ctx, "udp://127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("ListenPacketURI: %v", err)
+		}
+		defer conn.Close()
+
+		client, err := net.Dial("udp", conn.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		checkPacket(t, conn, client)
+	})
+
+	t.Run("unixgram", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sock")
+
+		// Leave a stale socket file behind, which should be removed.
+		stale, err := net.ListenPacket("unixgram", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale.Close()
+
+		conn, err := unixtransport.ListenPacketURI(ctx, "unixgram://"+socket+"?mode=0600")
+		if err != nil {
+			t.Fatalf("ListenPacketURI: %v", err)
+		}
+
+		fi, err := os.Stat(socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := os.FileMode(0o600), fi.Mode().Perm(); want != have {
+			t.Errorf("mode: want %v, have %v", want, have)
+		}
+
+		// A live socket shouldn't be removed.
+		var inUse *unixtransport.SocketInUseError
+		if _, err := unixtransport.ListenPacketURI(ctx, "unixgram://"+socket); !errors.As(err, &inUse) {
+			t.Errorf("second ListenPacketURI: want SocketInUseError, have %v", err)
+		}
+
+		client, err := net.Dial("unixgram", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		checkPacket(t, conn, client)
+
+		conn.Close()
+		if _, err := os.Stat(socket); !os.IsNotExist(err) {
+			t.Errorf("socket file after Close: want not-exist error, have %v", err)
+		}
+	})
+
+	t.Run("wrong network", func(t *testing.T) {
+		if _, err := unixtransport.ListenPacketURI(ctx, "tcp://127.0.0.1:0"); err == nil {
+			t.Errorf("ListenPacketURI(tcp): want error, have none")
+		}
+		if _, err := unixtransport.ListenURI(ctx, "udp://127.0.0.1:0"); err == nil {
+			t.Errorf("ListenURI(udp): want error, have none")
+		}
+	})
+}
+
+func checkPacket(t *testing.T, conn net.PacketConn, client net.Conn) {
+	t.Helper()
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if want, have := "hello", string(buf[:n]); want != have {
+		t.Errorf("read: want %q, have %q", want, have)
+	}
+}
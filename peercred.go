@@ -0,0 +1,61 @@
+package unixtransport
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// PeerCred identifies the process on the other end of a Unix socket
+// connection, as of the time it connected.
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+// PeerCredFromConn returns the credentials of the peer of conn, which must be
+// a Unix socket connection, like the connections accepted by listeners from
+// [ListenURI] on Unix networks. Connections wrapped by TLS, like the ones
+// accepted by "tls+unix" listeners, are unwrapped first.
+//
+// Peer credentials are currently only supported on Linux, via SO_PEERCRED.
+func PeerCredFromConn(conn net.Conn) (PeerCred, error) {
+	for {
+		switch c := conn.(type) {
+		case *net.UnixConn:
+			return peerCred(c)
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return PeerCred{}, fmt.Errorf("peer credentials: %T isn't a Unix socket connection", conn)
+		}
+	}
+}
+
+// PeerCredConnContext is meant to be used as the ConnContext of an
+// [http.Server]. If the connection is a Unix socket connection, it stores the
+// peer credentials in the context, where handlers can get them via
+// [PeerCredFromContext].
+//
+//	server := &http.Server{
+//		Handler:     handler,
+//		ConnContext: unixtransport.PeerCredConnContext,
+//	}
+func PeerCredConnContext(ctx context.Context, conn net.Conn) context.Context {
+	cred, err := PeerCredFromConn(conn)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, peerCredContextKey{}, cred)
+}
+
+// PeerCredFromContext returns the peer credentials stored in the context by
+// [PeerCredConnContext]. It returns false if there are none, e.g. because the
+// request didn't come in over a Unix socket.
+func PeerCredFromContext(ctx context.Context) (PeerCred, bool) {
+	cred, ok := ctx.Value(peerCredContextKey{}).(PeerCred)
+	return cred, ok
+}
+
+type peerCredContextKey struct{}
@@ -0,0 +1,34 @@
+package unixtransport
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// peerCred reads the peer credentials of conn via SO_PEERCRED.
+func peerCred(conn *net.UnixConn) (PeerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCred{}, fmt.Errorf("peer credentials: %w", err)
+	}
+
+	var (
+		ucred   *syscall.Ucred
+		credErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return PeerCred{}, fmt.Errorf("peer credentials: %w", err)
+	}
+	if credErr != nil {
+		return PeerCred{}, fmt.Errorf("peer credentials: %w", credErr)
+	}
+
+	return PeerCred{
+		PID: int(ucred.Pid),
+		UID: int(ucred.Uid),
+		GID: int(ucred.Gid),
+	}, nil
+}
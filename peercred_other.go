@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package unixtransport
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// peerCred isn't supported on this platform.
+func peerCred(conn *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, fmt.Errorf("peer credentials aren't supported on %s", runtime.GOOS)
+}
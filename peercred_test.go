@@ -0,0 +1,177 @@
+//go:build linux
+// +build linux
+
+package unixtransport_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestPeerCredFromConn(t *testing.T) {
+	t.Parallel()
+
+	var (
+		tempdir = t.TempDir()
+		cert    = newTestCert(t, tempdir, "server", nil)
+		socket  = filepath.Join(tempdir, "peer.sock")
+		want    = unixtransport.PeerCred{PID: os.Getpid(), UID: os.Getuid(), GID: os.Getgid()}
+	)
+
+	for _, testcase := range []struct {
+		name string
+		uri  string
+		dial func(net.Addr) (net.Conn, error)
+	}{
+		{
+			name: "unix",
+			uri:  "unix://" + socket,
+			dial: func(addr net.Addr) (net.Conn, error) { return net.Dial("unix", addr.String()) },
+		},
+		{
+			name: "abstract",
+			uri:  "unix://" + abstractName(t),
+			dial: func(addr net.Addr) (net.Conn, error) { return net.Dial("unix", addr.String()) },
+		},
+		{
+			name: "tls+unix",
+			uri:  "tls+unix://" + socket + "?cert=" + cert.certFile + "&key=" + cert.keyFile,
+			dial: func(addr net.Addr) (net.Conn, error) {
+				return tls.Dial("unix", addr.String(), &tls.Config{RootCAs: cert.pool(), ServerName: "localhost"})
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			ln, err := unixtransport.ListenURI(context.Background(), testcase.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					close(accepted)
+					return
+				}
+				if tc, ok := conn.(*tls.Conn); ok {
+					tc.Handshake()
+				}
+				accepted <- conn
+			}()
+
+			client, err := testcase.dial(ln.Addr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			conn, ok := <-accepted
+			if !ok {
+				t.Fatal("accept failed")
+			}
+			defer conn.Close()
+
+			have, err := unixtransport.PeerCredFromConn(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want != have {
+				t.Errorf("want %+v, have %+v", want, have)
+			}
+		})
+	}
+
+	t.Run("tcp", func(t *testing.T) {
+		ln := serveGreeting(t, "tcp", "127.0.0.1:0")
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if cred, err := unixtransport.PeerCredFromConn(conn); err == nil {
+			t.Errorf("want error, have %+v", cred)
+		}
+	})
+}
+
+func TestPeerCredConnContext(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := unixtransport.PeerCredFromContext(r.Context())
+		if !ok {
+			fmt.Fprintln(w, "no peer credentials")
+			return
+		}
+		fmt.Fprintln(w, cred.PID, cred.UID, cred.GID)
+	})
+
+	transport := &http.Transport{}
+	unixtransport.Register(transport)
+	client := &http.Client{Transport: transport}
+
+	// Requests over Unix sockets should have peer credentials.
+	{
+		socket := filepath.Join(t.TempDir(), "peer.sock")
+		ln, err := unixtransport.ListenURI(context.Background(), "unix://"+socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servePeerCred(t, ln, handler)
+
+		var (
+			rawurl = "http+unix://" + socket + ":/"
+			want   = fmt.Sprint(os.Getpid(), os.Getuid(), os.Getgid())
+			have   = get(t, client, rawurl)
+		)
+		if want != have {
+			t.Errorf("%s: want %q, have %q", rawurl, want, have)
+		}
+	}
+
+	// Requests over TCP shouldn't.
+	{
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := servePeerCred(t, ln, handler)
+
+		var (
+			rawurl = server.URL
+			want   = "no peer credentials"
+			have   = get(t, client, rawurl)
+		)
+		if want != have {
+			t.Errorf("%s: want %q, have %q", rawurl, want, have)
+		}
+	}
+}
+
+// servePeerCred serves handler on ln until the test is done, with peer
+// credentials in the request context.
+func servePeerCred(t *testing.T, ln net.Listener, handler http.Handler) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = ln
+	server.Config.ConnContext = unixtransport.PeerCredConnContext
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return server
+}
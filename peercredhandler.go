@@ -0,0 +1,97 @@
+package unixtransport
+
+import (
+	"net/http"
+	"os/user"
+	"strconv"
+)
+
+// PeerCredHandler is an http.Handler that only allows requests from peers with
+// allowed Unix users or groups, and responds with 403 Forbidden to all other
+// requests. It's meant to guard e.g. admin endpoints served on Unix sockets.
+//
+// Peer credentials are taken from the request context, so the server must use
+// [PeerCredConnContext]. Requests without peer credentials, like requests over
+// TCP, are always denied. If no users or groups are allowed, all requests are
+// denied.
+type PeerCredHandler struct {
+	// Next handles allowed requests.
+	Next http.Handler
+
+	// UIDs allows peers with any of these user IDs.
+	UIDs []int
+
+	// GIDs allows peers with any of these primary group IDs.
+	GIDs []int
+
+	// Users allows peers whose user ID maps to any of these user names.
+	Users []string
+
+	// Groups allows peers whose user is a member of any of these groups, by
+	// name or numeric ID. Unlike GIDs, supplementary groups are considered.
+	Groups []string
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PeerCredHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cred, ok := PeerCredFromContext(r.Context())
+	if !ok || !h.allow(cred) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	h.Next.ServeHTTP(w, r)
+}
+
+func (h *PeerCredHandler) allow(cred PeerCred) bool {
+	if containsInt(h.UIDs, cred.UID) || containsInt(h.GIDs, cred.GID) {
+		return true
+	}
+
+	if len(h.Users) == 0 && len(h.Groups) == 0 {
+		return false
+	}
+
+	// User and group names require lookups, which only happen if the
+	// allowlists of IDs didn't match already.
+	u, err := user.LookupId(strconv.Itoa(cred.UID))
+	if err != nil {
+		return false
+	}
+
+	for _, name := range h.Users {
+		if name == u.Username {
+			return true
+		}
+	}
+
+	if len(h.Groups) == 0 {
+		return false
+	}
+
+	gids := []int{cred.GID}
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if gid, err := strconv.Atoi(id); err == nil {
+				gids = append(gids, gid)
+			}
+		}
+	}
+
+	for _, name := range h.Groups {
+		if gid, err := lookupGID(name); err == nil && containsInt(gids, gid) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsInt(a []int, n int) bool {
+	for _, x := range a {
+		if x == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package unixtransport_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestPeerCredHandler(t *testing.T) {
+	t.Parallel()
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("current user: %v", err)
+	}
+
+	group, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	if err != nil {
+		t.Skipf("current group: %v", err)
+	}
+
+	var (
+		uid = os.Getuid()
+		gid = os.Getgid()
+		ok  = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "OK") })
+	)
+
+	transport := &http.Transport{}
+	unixtransport.Register(transport)
+	client := &http.Client{Transport: transport}
+
+	for _, testcase := range []struct {
+		name    string
+		handler *unixtransport.PeerCredHandler
+		want    int
+	}{
+		{name: "no allowlists", handler: &unixtransport.PeerCredHandler{}, want: http.StatusForbidden},
+		{name: "uid", handler: &unixtransport.PeerCredHandler{UIDs: []int{uid}}, want: http.StatusOK},
+		{name: "other uid", handler: &unixtransport.PeerCredHandler{UIDs: []int{uid + 1}}, want: http.StatusForbidden},
+		{name: "gid", handler: &unixtransport.PeerCredHandler{GIDs: []int{gid}}, want: http.StatusOK},
+		{name: "other gid", handler: &unixtransport.PeerCredHandler{GIDs: []int{gid + 1}}, want: http.StatusForbidden},
+		{name: "user", handler: &unixtransport.PeerCredHandler{Users: []string{current.Username}}, want: http.StatusOK},
+		{name: "other user", handler: &unixtransport.PeerCredHandler{Users: []string{"no-such-user"}}, want: http.StatusForbidden},
+		{name: "group", handler: &unixtransport.PeerCredHandler{Groups: []string{group.Name}}, want: http.StatusOK},
+		{name: "group id", handler: &unixtransport.PeerCredHandler{Groups: []string{group.Gid}}, want: http.StatusOK},
+		{name: "other group", handler: &unixtransport.PeerCredHandler{Groups: []string{"no-such-group"}}, want: http.StatusForbidden},
+		{name: "any match", handler: &unixtransport.PeerCredHandler{UIDs: []int{uid + 1}, Users: []string{current.Username}}, want: http.StatusOK},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			socket := filepath.Join(t.TempDir(), "admin.sock")
+			ln, err := unixtransport.ListenURI(context.Background(), "unix://"+socket)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testcase.handler.Next = ok
+			servePeerCred(t, ln, testcase.handler)
+
+			rawurl := "http+unix://" + socket + ":/admin"
+			resp, err := client.Get(rawurl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if want, have := testcase.want, resp.StatusCode; want != have {
+				t.Errorf("GET %s: want %d, have %d", rawurl, want, have)
+			}
+		})
+	}
+
+	// Requests without peer credentials are always denied.
+	t.Run("tcp", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server := servePeerCred(t, ln, &unixtransport.PeerCredHandler{Next: ok, UIDs: []int{uid}})
+
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if want, have := http.StatusForbidden, resp.StatusCode; want != have {
+			t.Errorf("GET %s: want %d, have %d", server.URL, want, have)
+		}
+	})
+}
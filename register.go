@@ -2,26 +2,75 @@ package unixtransport
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"fmt"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 // Register adds a protocol handler to the provided transport that can serve
 // requests to Unix domain sockets via the "http+unix" or "https+unix" schemes.
-// Request URLs should have the following form:
+// The handler is constructed by [NewTransport], see that function for details
+// on request URLs and configuration. Note that CloseIdleConnections on t
+// doesn't reach the connections to Unix sockets, which are pooled by the
+// handler; use [NewTransport] directly if that matters.
+func Register(t *http.Transport, options ...Option) {
+	tt := NewTransport(t, options...)
+
+	t.RegisterProtocol("http+unix", tt)
+	t.RegisterProtocol("https+unix", tt)
+}
+
+// NewTransport returns a round tripper that can serve requests to Unix domain
+// sockets via the "http+unix" or "https+unix" schemes, and rejects requests
+// with any other scheme. Unlike [Register], it leaves the base transport
+// untouched. Request URLs should have the following form:
 //
 //	https+unix:///path/to/socket:/request/path?query=val&...
 //
-// The registered transport is based on a clone of the provided transport, and
-// so uses the same configuration: timeouts, TLS settings, and so on. Connection
-// pooling should also work as normal. One caveat: only the DialContext and
-// DialTLSContext dialers are respected; the Dial and DialTLS dialers are
-// explicitly removed and ignored. Any configured Proxy is also discarded.
-func Register(t *http.Transport) {
-	copy := t.Clone()
+// Socket paths that contain a ':' can be addressed by percent-encoding every
+// ':' in the socket path as "%3A". The first literal ':' always separates the
+// socket path from the request path. See [NewURL] to build such URLs.
+//
+// On Linux, sockets in the abstract namespace can be addressed by putting an
+// '@' in front of the socket name, which must be a valid URL host. Abstract
+// names that start with a '/' use the path form after the '@' instead.
+//
+//	https+unix://@name:/request/path?query=val&...
+//	https+unix://@/abstract/name:/request/path?query=val&...
+//
+// Sockets can also be identified by a logical name, which is resolved to a
+// socket path whenever a new connection is dialed. See [SocketResolver] and
+// [WithResolver].
+//
+//	https+unix://name:/request/path?query=val&...
+//
+// By default, the Host header of a request is an opaque encoding of the socket
+// path, and HTTPS requests need an explicit ServerName in the TLS client config
+// of the base transport. To use a meaningful host instead, set the Host field
+// of the request. That host is then used for the Host header, and, for HTTPS,
+// as the TLS server name, overriding any configured ServerName.
+//
+// The returned transport is based on a clone of the base transport, and so uses
+// the same configuration: timeouts, TLS settings, and so on. Connection pooling
+// should also work as normal, and the returned transport implements
+// CloseIdleConnections, so [http.Client.CloseIdleConnections] works too. One
+// caveat: only the DialContext and DialTLSContext dialers are respected; the
+// Dial and DialTLS dialers are explicitly removed and ignored. Any configured
+// Proxy is also discarded.
+func NewTransport(base *http.Transport, options ...Option) http.RoundTripper {
+	var config transportConfig
+	for _, option := range options {
+		option(&config)
+	}
+	if config.resolver == nil {
+		config.resolver = DefaultResolver()
+	}
+
+	copy := base.Clone()
 
 	copy.Dial = nil    //lint:ignore SA1019 yes, it's deprecated, that's the point
 	copy.DialTLS = nil //lint:ignore SA1019 yes, it's deprecated, that's the point
@@ -29,43 +78,55 @@ func Register(t *http.Transport) {
 
 	switch {
 	case copy.DialContext == nil && copy.DialTLSContext == nil:
-		copy.DialContext = dialContextAdapter(defaultDialContextFunc)
+		copy.DialContext = dialContextAdapter(config.resolver, defaultDialContextFunc)
 
 	case copy.DialContext == nil && copy.DialTLSContext != nil:
-		copy.DialContext = dialContextAdapter(defaultDialContextFunc)
-		copy.DialTLSContext = dialContextAdapter(copy.DialTLSContext)
+		copy.DialContext = dialContextAdapter(config.resolver, defaultDialContextFunc)
+		copy.DialTLSContext = dialContextAdapter(config.resolver, copy.DialTLSContext)
 
 	case copy.DialContext != nil && copy.DialTLSContext == nil:
-		copy.DialContext = dialContextAdapter(copy.DialContext)
+		copy.DialContext = dialContextAdapter(config.resolver, copy.DialContext)
 
 	case copy.DialContext != nil && copy.DialTLSContext != nil:
-		copy.DialContext = dialContextAdapter(copy.DialContext)
-		copy.DialTLSContext = dialContextAdapter(copy.DialTLSContext)
+		copy.DialContext = dialContextAdapter(config.resolver, copy.DialContext)
+		copy.DialTLSContext = dialContextAdapter(config.resolver, copy.DialTLSContext)
 	}
 
-	tt := roundTripAdapter(copy)
-
-	t.RegisterProtocol("http+unix", tt)
-	t.RegisterProtocol("https+unix", tt)
+	return roundTripAdapter(copy)
 }
 
 // RegisterDefault calls [Register] with the [http.DefaultTransport], which is
 // assumed to be a pointer to an [http.Transport]. Returns true if the
 // registration succeeded, and false otherwise.
-func RegisterDefault() bool {
+func RegisterDefault(options ...Option) bool {
 	t, ok := http.DefaultTransport.(*http.Transport)
 	if !ok {
 		return false
 	}
 
-	Register(t)
+	Register(t, options...)
 	return true
 }
 
+// Option configures the transport constructed by [NewTransport], [Register],
+// or [RegisterDefault].
+type Option func(*transportConfig)
+
+// WithResolver sets the resolver used to resolve logical socket names to socket
+// paths. By default, transports use [DefaultResolver].
+func WithResolver(r SocketResolver) Option {
+	return func(c *transportConfig) { c.resolver = r }
+}
+
+type transportConfig struct {
+	resolver SocketResolver
+}
+
 // dialContextAdapter decorates the provided DialContext function by trying to base64 decode
 // the provided address. If successful, the network is changed to "unix" and the address
-// is changed to the decoded value.
-func dialContextAdapter(next dialContextFunc) dialContextFunc {
+// is changed to the decoded value. Decoded values that are logical socket names are
+// resolved to socket paths via the resolver.
+func dialContextAdapter(resolver SocketResolver, next dialContextFunc) dialContextFunc {
 	return func(ctx context.Context, network, address string) (net.Conn, error) {
 		host, _, err := net.SplitHostPort(address)
 		if err != nil {
@@ -77,40 +138,181 @@ func dialContextAdapter(next dialContextFunc) dialContextFunc {
 			network, address = "unix", string(filepath)
 		}
 
+		if network == "unix" && isSocketName(address) {
+			socketPath, err := resolver.ResolveSocket(ctx, address)
+			if err != nil {
+				return nil, fmt.Errorf("resolve socket: %w", err)
+			}
+			address = socketPath
+		}
+
 		return next(ctx, network, address)
 	}
 }
 
+// unixTransport is the http.RoundTripper returned by NewTransport. It also
+// implements CloseIdleConnections, so http.Client.CloseIdleConnections reaches
+// the cloned transport and its per-server-name clones.
+type unixTransport struct {
+	next         *http.Transport
+	byServerName *serverNameTransports
+}
+
 // roundTripAdapter returns an http.RoundTripper which, when used in combination
 // with the dialContextAdapter, supports Unix sockets via any scheme with a
 // "+unix" suffix.
-func roundTripAdapter(next http.RoundTripper) http.RoundTripper {
-	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
-		if req.URL == nil {
-			return nil, fmt.Errorf("unix transport: no request URL")
+func roundTripAdapter(next *http.Transport) *unixTransport {
+	return &unixTransport{
+		next:         next,
+		byServerName: &serverNameTransports{base: next},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	uu, err := parseUnixURL(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("unix transport: %w", err)
+	}
+
+	encodedHost := base64.RawURLEncoding.EncodeToString([]byte(uu.socketPath))
+
+	// The request host is only meaningful if it was set explicitly, rather
+	// than copied from the URL by e.g. http.NewRequest.
+	var host string
+	if req.Host != req.URL.Host {
+		host = req.Host
+	}
+
+	req = req.Clone(req.Context())
+	req.Host = host
+	req.URL.Scheme = uu.scheme
+	req.URL.Host = encodedHost
+	req.URL.Path = uu.requestPath
+	req.URL.RawPath = uu.rawRequestPath
+
+	if req.URL.User != nil {
+		// The http.Client turns any userinfo into basic auth, including the
+		// empty userinfo of an abstract socket URL. Undo that.
+		if req.URL.User.String() == "" && req.Header.Get("Authorization") == emptyBasicAuth {
+			req.Header.Del("Authorization")
 		}
+		req.URL.User = nil
+	}
 
-		scheme := strings.TrimSuffix(req.URL.Scheme, "+unix")
-		if scheme == req.URL.Scheme {
-			return nil, fmt.Errorf("unix transport: missing '+unix' suffix in scheme %s", req.URL.Scheme)
+	if host != "" && uu.scheme == "https" {
+		return t.byServerName.get(host).RoundTrip(req)
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the cloned transport, and
+// of every per-server-name clone.
+func (t *unixTransport) CloseIdleConnections() {
+	t.next.CloseIdleConnections()
+	t.byServerName.closeIdleConnections()
+}
+
+// maxServerNameTransports caps the number of per-server-name transports kept
+// by serverNameTransports.
+const maxServerNameTransports = 64
+
+// serverNameTransports lazily creates and caches clones of a base transport,
+// each with a specific TLS server name. There's one clone per distinct server
+// name, so each server name gets its own connection pool. At most
+// maxServerNameTransports clones are kept; when a new one is needed beyond
+// that, the least recently used clone is evicted, and its idle connections are
+// closed.
+type serverNameTransports struct {
+	base *http.Transport
+
+	mtx        sync.Mutex
+	transports map[string]*serverNameTransport
+	uses       uint64
+}
+
+type serverNameTransport struct {
+	*http.Transport
+	lastUse uint64
+}
+
+// get returns the transport for the server name of the given host, which may
+// include a port.
+func (s *serverNameTransports) get(host string) *http.Transport {
+	serverName := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		serverName = h
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.uses++
+
+	if t, ok := s.transports[serverName]; ok {
+		t.lastUse = s.uses
+		return t.Transport
+	}
+
+	if len(s.transports) >= maxServerNameTransports {
+		s.evictLocked()
+	}
+
+	t := s.base.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.ServerName = serverName
+
+	if s.transports == nil {
+		s.transports = map[string]*serverNameTransport{}
+	}
+	s.transports[serverName] = &serverNameTransport{Transport: t, lastUse: s.uses}
+
+	return t
+}
+
+// evictLocked removes the least recently used transport, and closes its idle
+// connections. Requests in flight on that transport are unaffected.
+func (s *serverNameTransports) evictLocked() {
+	var (
+		oldestName string
+		oldest     *serverNameTransport
+	)
+	for name, t := range s.transports {
+		if oldest == nil || t.lastUse < oldest.lastUse {
+			oldestName, oldest = name, t
 		}
+	}
+	if oldest == nil {
+		return
+	}
 
-		socketPath, requestPath, _ := strings.Cut(req.URL.Path, ":")
-		encodedHost := base64.RawURLEncoding.EncodeToString([]byte(socketPath))
+	delete(s.transports, oldestName)
+	oldest.CloseIdleConnections()
+}
 
-		req = req.Clone(req.Context())
-		req.URL.Scheme = scheme
-		req.URL.Host = encodedHost
-		req.URL.Path = requestPath
+// closeIdleConnections closes the idle connections of every transport.
+func (s *serverNameTransports) closeIdleConnections() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
 
-		return next.RoundTrip(req)
-	})
+	for _, t := range s.transports {
+		t.CloseIdleConnections()
+	}
 }
 
-type dialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+// isAbstractAddress returns true if the address identifies a Unix socket in
+// the abstract namespace, which has no representation on the filesystem.
+func isAbstractAddress(address string) bool {
+	return strings.HasPrefix(address, "@")
+}
 
-var defaultDialContextFunc = (&net.Dialer{}).DialContext
+// emptyBasicAuth is the Authorization header set by the http.Client for URLs
+// with an empty userinfo.
+var emptyBasicAuth = "Basic " + base64.StdEncoding.EncodeToString([]byte(":"))
 
-type roundTripFunc func(req *http.Request) (*http.Response, error)
+type dialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
 
-func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+var defaultDialContextFunc = (&net.Dialer{}).DialContext
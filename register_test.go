@@ -5,12 +5,14 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/peterbourgon/unixtransport"
 )
@@ -161,6 +163,176 @@ func TestRegisterDefault(t *testing.T) {
 	}
 }
 
+func TestRegisterHost(t *testing.T) {
+	t.Parallel()
+
+	// The httptest.Server TLS certificate is valid for "example.com". Note
+	// there's no explicit ServerName in the client TLS config.
+	var (
+		tempdir         = t.TempDir()
+		socket          = filepath.Join(tempdir, "1")
+		tlsClientConfig = &tls.Config{}
+	)
+	{
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, r.Host, r.TLS.ServerName) })
+		server := httptest.NewUnstartedServer(handler)
+		server.Listener = ln
+		server.Config.ErrorLog = log.New(io.Discard, "", 0) // expected handshake errors
+		server.StartTLS()
+		t.Cleanup(func() { server.Close() })
+
+		certpool := x509.NewCertPool()
+		certpool.AddCert(server.Certificate())
+		tlsClientConfig.RootCAs = certpool
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsClientConfig}
+	client := &http.Client{Transport: transport}
+	unixtransport.Register(transport)
+
+	rawurl := "https+unix://" + socket + ":/foo"
+
+	// Without an explicit host, certificate verification should fail.
+	if _, err := client.Get(rawurl); err == nil {
+		t.Errorf("GET %s without host: want error, have none", rawurl)
+	}
+
+	// With an explicit host, it should succeed, and the server should see that
+	// host both in the Host header, and as the TLS server name.
+	for _, host := range []string{"example.com", "example.com:443"} {
+		req, err := http.NewRequest("GET", rawurl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = host
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s with host %s: %v", rawurl, host, err)
+		}
+		buf, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if want, have := host+" example.com", strings.TrimSpace(string(buf)); want != have {
+			t.Errorf("GET %s with host %s: want %q, have %q", rawurl, host, want, have)
+		}
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "1")
+	{
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, 1, r.URL.Path) })
+		server := httptest.NewUnstartedServer(handler)
+		server.Listener = ln
+		server.Start()
+		t.Cleanup(func() { server.Close() })
+	}
+
+	base := &http.Transport{}
+	client := &http.Client{Transport: unixtransport.NewTransport(base)}
+
+	// The returned transport should serve http+unix requests.
+	uri := "http+unix://" + socket + ":/foo"
+	if want, have := "1 /foo", get(t, client, uri); want != have {
+		t.Errorf("GET %s: want %q, have %q", uri, want, have)
+	}
+
+	// It should reject requests with other schemes.
+	if _, err := client.Get("http://localhost/foo"); err == nil {
+		t.Errorf("GET http://localhost/foo: want error, have none")
+	}
+
+	// The base transport shouldn't have the protocol registered.
+	if _, err := (&http.Client{Transport: base}).Get(uri); err == nil {
+		t.Errorf("GET %s via base transport: want error, have none", uri)
+	}
+}
+
+func TestNewTransportCloseIdleConnections(t *testing.T) {
+	t.Parallel()
+
+	// Serve HTTP and HTTPS, and report every connection the servers close.
+	var (
+		tempdir         = t.TempDir()
+		closed          = make(chan struct{}, 10)
+		tlsClientConfig = &tls.Config{}
+	)
+	serve := func(name string, useTLS bool) string {
+		socket := filepath.Join(tempdir, name)
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "ok") })
+		server := httptest.NewUnstartedServer(handler)
+		server.Listener = ln
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				closed <- struct{}{}
+			}
+		}
+		if useTLS {
+			server.StartTLS()
+			certpool := x509.NewCertPool()
+			certpool.AddCert(server.Certificate())
+			tlsClientConfig.RootCAs = certpool
+		} else {
+			server.Start()
+		}
+		t.Cleanup(func() { server.Close() })
+		return socket
+	}
+
+	var (
+		plain  = serve("plain", false)
+		secure = serve("secure", true)
+		client = &http.Client{Transport: unixtransport.NewTransport(&http.Transport{TLSClientConfig: tlsClientConfig})}
+	)
+
+	// The HTTPS request with an explicit host uses a per-server-name transport.
+	if want, have := "ok", get(t, client, "http+unix://"+plain+":/"); want != have {
+		t.Fatalf("GET plain: want %q, have %q", want, have)
+	}
+	req, err := http.NewRequest("GET", "https+unix://"+secure+":/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "example.com"
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET secure: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// Both pooled connections should be closed.
+	client.CloseIdleConnections()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for connection %d to be closed", i+1)
+		}
+	}
+}
+
 func get(t *testing.T, client *http.Client, rawurl string) string {
 	t.Helper()
 
@@ -0,0 +1,138 @@
+package unixtransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SocketResolver resolves logical socket names to socket paths. Transports
+// constructed by [NewTransport] or [Register] consult a resolver when dialing
+// URLs that identify a socket by name rather than by path, e.g.
+//
+//	http+unix://myapp:/request/path
+//
+// Implementations should return an error satisfying errors.Is(err,
+// os.ErrNotExist) if the name isn't known.
+type SocketResolver interface {
+	ResolveSocket(ctx context.Context, name string) (socketPath string, err error)
+}
+
+// SocketResolverFunc adapts a function to a [SocketResolver].
+type SocketResolverFunc func(ctx context.Context, name string) (string, error)
+
+// ResolveSocket implements [SocketResolver] by calling f.
+func (f SocketResolverFunc) ResolveSocket(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// DirResolver resolves a name to a socket in one of the directories, which are
+// searched in order. In each directory, it looks for a socket with the name
+// itself, and then with the name plus a ".sock" suffix. Names must be single
+// path elements.
+type DirResolver []string
+
+// ResolveSocket implements [SocketResolver].
+func (r DirResolver) ResolveSocket(ctx context.Context, name string) (string, error) {
+	if err := validateSocketName(name); err != nil {
+		return "", err
+	}
+
+	for _, dir := range r {
+		for _, filename := range []string{name, name + ".sock"} {
+			socketPath := filepath.Join(dir, filename)
+			if fi, err := os.Stat(socketPath); err == nil && fi.Mode()&os.ModeSocket != 0 {
+				return socketPath, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("socket %s not found in %s: %w", name, strings.Join(r, ", "), os.ErrNotExist)
+}
+
+// EnvResolver resolves a name to the socket path in an environment variable.
+// The variable is the prefix followed by the name in upper case, with every
+// character that isn't a letter or digit replaced by an underscore. For
+// example, with prefix "UNIX_SOCKET_", the name "my-app" is resolved via the
+// variable UNIX_SOCKET_MY_APP.
+type EnvResolver struct {
+	Prefix string
+}
+
+// ResolveSocket implements [SocketResolver].
+func (r EnvResolver) ResolveSocket(ctx context.Context, name string) (string, error) {
+	if err := validateSocketName(name); err != nil {
+		return "", err
+	}
+
+	key := r.Prefix + strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z':
+			return c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			return c
+		default:
+			return '_'
+		}
+	}, name)
+
+	socketPath := os.Getenv(key)
+	if socketPath == "" {
+		return "", fmt.Errorf("socket %s not found in environment variable %s: %w", name, key, os.ErrNotExist)
+	}
+
+	return socketPath, nil
+}
+
+// MultiResolver tries each resolver in order, and returns the first socket
+// path that's found. Errors other than [os.ErrNotExist] are returned
+// immediately.
+type MultiResolver []SocketResolver
+
+// ResolveSocket implements [SocketResolver].
+func (r MultiResolver) ResolveSocket(ctx context.Context, name string) (string, error) {
+	for _, resolver := range r {
+		socketPath, err := resolver.ResolveSocket(ctx, name)
+		switch {
+		case err == nil:
+			return socketPath, nil
+		case !errors.Is(err, os.ErrNotExist):
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("socket %s not found: %w", name, os.ErrNotExist)
+}
+
+// DefaultResolver returns the resolver used by transports that aren't
+// configured with an explicit resolver. It first consults environment
+// variables with prefix "UNIX_SOCKET_", see [EnvResolver], and then searches
+// $XDG_RUNTIME_DIR (if set), /run, and /var/run, see [DirResolver].
+func DefaultResolver() SocketResolver {
+	var dirs DirResolver
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, "/run", "/var/run")
+
+	return MultiResolver{
+		EnvResolver{Prefix: "UNIX_SOCKET_"},
+		dirs,
+	}
+}
+
+func validateSocketName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid socket name %q", name)
+	}
+	return nil
+}
+
+// isSocketName returns true if the socket path is actually a logical name,
+// which must be resolved to get a real socket path.
+func isSocketName(socketPath string) bool {
+	return !strings.HasPrefix(socketPath, "/") && !isAbstractAddress(socketPath)
+}
@@ -0,0 +1,153 @@
+package unixtransport_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestDirResolver(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx  = context.Background()
+		dir1 = t.TempDir()
+		dir2 = t.TempDir()
+	)
+
+	listen(t, filepath.Join(dir1, "foo"))
+	listen(t, filepath.Join(dir2, "foo"))
+	listen(t, filepath.Join(dir2, "bar.sock"))
+	if err := os.WriteFile(filepath.Join(dir1, "baz"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := unixtransport.DirResolver{dir1, dir2}
+
+	for _, testcase := range []struct {
+		name       string
+		socketPath string
+		notExist   bool
+		err        bool
+	}{
+		{name: "foo", socketPath: filepath.Join(dir1, "foo")},
+		{name: "bar", socketPath: filepath.Join(dir2, "bar.sock")},
+		{name: "baz", notExist: true}, // not a socket
+		{name: "qux", notExist: true},
+		{name: "..", err: true},
+		{name: "a/b", err: true},
+	} {
+		socketPath, err := resolver.ResolveSocket(ctx, testcase.name)
+		switch {
+		case testcase.notExist && !errors.Is(err, os.ErrNotExist):
+			t.Errorf("%s: want not-exist error, have %v", testcase.name, err)
+		case testcase.err && err == nil:
+			t.Errorf("%s: want error, have none", testcase.name)
+		case !testcase.notExist && !testcase.err && err != nil:
+			t.Errorf("%s: want no error, have %v", testcase.name, err)
+		case socketPath != testcase.socketPath:
+			t.Errorf("%s: want %q, have %q", testcase.name, testcase.socketPath, socketPath)
+		}
+	}
+}
+
+func TestEnvResolver(t *testing.T) {
+	t.Setenv("TEST_SOCKET_MY_APP_2", "/tmp/my-app-2.sock")
+
+	ctx := context.Background()
+	resolver := unixtransport.EnvResolver{Prefix: "TEST_SOCKET_"}
+
+	if want, have := "/tmp/my-app-2.sock", resolveSocket(t, resolver, "my-app.2"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	if _, err := resolver.ResolveSocket(ctx, "other"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want not-exist error, have %v", err)
+	}
+}
+
+func TestMultiResolver(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx   = context.Background()
+		dir   = t.TempDir()
+		fails = unixtransport.SocketResolverFunc(func(context.Context, string) (string, error) {
+			return "", fmt.Errorf("resolver failed")
+		})
+	)
+
+	listen(t, filepath.Join(dir, "foo"))
+
+	resolver := unixtransport.MultiResolver{unixtransport.DirResolver{t.TempDir()}, unixtransport.DirResolver{dir}, fails}
+	if want, have := filepath.Join(dir, "foo"), resolveSocket(t, resolver, "foo"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	if _, err := resolver.ResolveSocket(ctx, "bar"); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want resolver error, have %v", err)
+	}
+}
+
+func TestWithResolver(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ln := listen(t, filepath.Join(dir, "myapp.sock"))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "myapp", r.URL.Path) })
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = ln
+	server.Start()
+	t.Cleanup(func() { server.Close() })
+
+	transport := &http.Transport{}
+	unixtransport.Register(transport, unixtransport.WithResolver(unixtransport.DirResolver{dir}))
+	client := &http.Client{Transport: transport}
+
+	for rawurl, want := range map[string]string{
+		"http+unix://myapp:/foo":                "myapp /foo",
+		"http+unix://myapp/bar":                 "myapp /bar",
+		"http+unix://myapp":                     "myapp /",
+		newURL(t, "http+unix", "myapp", "/baz"): "myapp /baz",
+	} {
+		if have := get(t, client, rawurl); want != have {
+			t.Errorf("%s: want %q, have %q", rawurl, want, have)
+		}
+	}
+
+	if _, err := client.Get("http+unix://other:/foo"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unknown name: want not-exist error, have %v", err)
+	}
+}
+
+func listen(t *testing.T, socketPath string) net.Listener {
+	t.Helper()
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	return ln
+}
+
+func resolveSocket(t *testing.T, resolver unixtransport.SocketResolver, name string) string {
+	t.Helper()
+
+	socketPath, err := resolver.ResolveSocket(context.Background(), name)
+	if err != nil {
+		t.Fatalf("ResolveSocket(%q): %v", name, err)
+	}
+
+	return socketPath
+}
@@ -0,0 +1,118 @@
+package unixtransport
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor passed via socket activation, see
+// sd_listen_fds(3). It's a variable so tests can use other descriptors.
+var listenFDsStart = 3
+
+// systemdAdopted records the passed sockets which have already been adopted.
+// Adopting a socket closes its file descriptor, after which the number can be
+// reused by an unrelated file, which must not be adopted in turn.
+var systemdAdopted = struct {
+	sync.Mutex
+	fds map[int]bool
+}{fds: map[int]bool{}}
+
+// inheritedFile returns the inherited socket identified by the network, which
+// must be "fd" or "systemd", and the address. The caller should construct a
+// listener or packet conn from the file, which makes a duplicate of the file
+// descriptor, and then close the file.
+func inheritedFile(network, address string) (*os.File, error) {
+	switch network {
+	case "fd":
+		return fdFile(address)
+	case "systemd":
+		return systemdFile(address)
+	default:
+		return nil, fmt.Errorf("invalid network %s for inherited socket", network)
+	}
+}
+
+// fdFile returns the inherited file descriptor identified by address, which
+// must be a non-negative integer.
+func fdFile(address string) (*os.File, error) {
+	fd, err := strconv.Atoi(address)
+	if err != nil || fd < 0 {
+		return nil, fmt.Errorf("invalid file descriptor %q", address)
+	}
+
+	return newFile(fd, "fd:"+address)
+}
+
+// systemdFile returns a socket passed by systemd socket activation. If name is
+// empty, the first passed socket which hasn't been adopted yet is used.
+// Otherwise, the socket with that name in LISTEN_FDNAMES is used. Each socket
+// can only be adopted once.
+func systemdFile(name string) (*os.File, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: invalid or missing LISTEN_PID")
+	}
+
+	if pid != os.Getpid() {
+		return nil, fmt.Errorf("socket activation: LISTEN_PID %d doesn't match process %d", pid, os.Getpid())
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("socket activation: invalid or missing LISTEN_FDS")
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("socket activation: no sockets passed")
+	}
+
+	var names []string
+	if s := os.Getenv("LISTEN_FDNAMES"); s != "" {
+		names = strings.Split(s, ":")
+	}
+
+	systemdAdopted.Lock()
+	defer systemdAdopted.Unlock()
+
+	for i := 0; i < count; i++ {
+		var fdname string
+		if i < len(names) {
+			fdname = names[i]
+		}
+
+		fd := listenFDsStart + i
+		adopted := systemdAdopted.fds[fd]
+
+		switch {
+		case name == "" && adopted:
+			continue
+		case name == "" || name == fdname:
+			if adopted {
+				return nil, fmt.Errorf("socket activation: socket %q has already been adopted", name)
+			}
+			f, err := newFile(fd, "systemd:"+fdname)
+			if err != nil {
+				return nil, err
+			}
+			systemdAdopted.fds[fd] = true
+			return f, nil
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("socket activation: all passed sockets have already been adopted")
+	}
+
+	return nil, fmt.Errorf("socket activation: no socket named %q in LISTEN_FDNAMES", name)
+}
+
+func newFile(fd int, name string) (*os.File, error) {
+	f := os.NewFile(uintptr(fd), name)
+	if f == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	return f, nil
+}
@@ -0,0 +1,193 @@
+//go:build linux
+// +build linux
+
+package unixtransport
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenURISystemd(t *testing.T) {
+	// Simulate socket activation by duplicating the file descriptors of two
+	// listeners into a contiguous block, and pointing listenFDsStart at it.
+	const start = 900
+
+	defer func(prev int) { listenFDsStart = prev }(listenFDsStart)
+	listenFDsStart = start
+
+	systemdAdopted.Lock()
+	systemdAdopted.fds = map[int]bool{}
+	systemdAdopted.Unlock()
+
+	// ListenURI closes the file descriptors it adopts, after which their numbers
+	// can be reused, so only close the ones that are still owned by the test.
+	dir := t.TempDir()
+	addrs := make([]string, 2)
+	owned := make([]bool, len(addrs))
+	defer func() {
+		for i := range owned {
+			if owned[i] {
+				syscall.Close(start + i)
+			}
+		}
+	}()
+	for i := range addrs {
+		ln, err := net.Listen("unix", filepath.Join(dir, strconv.Itoa(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		f, err := ln.(*net.UnixListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		if err := syscall.Dup3(int(f.Fd()), start+i, syscall.O_CLOEXEC); err != nil {
+			t.Fatalf("Dup3: %v", err)
+		}
+		owned[i] = true
+
+		addrs[i] = ln.Addr().String()
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "first:second")
+
+	ctx := context.Background()
+
+	// Adopt the second socket by name.
+	ln, err := ListenURI(ctx, "systemd://second")
+	if err != nil {
+		t.Fatalf("ListenURI(systemd://second): %v", err)
+	}
+	owned[1] = false
+	defer ln.Close()
+	if want, have := addrs[1], ln.Addr().String(); want != have {
+		t.Errorf("systemd://second: want %s, have %s", want, have)
+	}
+	checkAccept(t, ln)
+
+	// Adopt the first socket by default.
+	ln, err = ListenURI(ctx, "systemd://")
+	if err != nil {
+		t.Fatalf("ListenURI(systemd://): %v", err)
+	}
+	owned[0] = false
+	defer ln.Close()
+	if want, have := addrs[0], ln.Addr().String(); want != have {
+		t.Errorf("systemd://: want %s, have %s", want, have)
+	}
+	checkAccept(t, ln)
+
+	// Sockets that have already been adopted should fail, even though their
+	// file descriptor numbers may have been reused by now, e.g. by an unrelated
+	// listener, which must be left alone.
+	other, err := net.Listen("unix", filepath.Join(dir, "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	otherFile, err := other.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherFile.Close()
+	if err := syscall.Dup3(int(otherFile.Fd()), start, syscall.O_CLOEXEC); err != nil {
+		t.Fatalf("Dup3: %v", err)
+	}
+	owned[0] = true
+
+	for _, uri := range []string{"systemd://", "systemd://first", "systemd://second"} {
+		if ln, err := ListenURI(ctx, uri); err == nil {
+			ln.Close()
+			t.Errorf("%s again: want error, have none", uri)
+		}
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(start, &stat); err != nil {
+		t.Errorf("unrelated file descriptor: %v", err)
+	}
+
+	// Unknown names should fail.
+	if _, err := ListenURI(ctx, "systemd://third"); err == nil {
+		t.Errorf("systemd://third: want error, have none")
+	}
+
+	// Sockets passed to another process should fail.
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	if _, err := ListenURI(ctx, "systemd://"); err == nil {
+		t.Errorf("systemd:// with other LISTEN_PID: want error, have none")
+	}
+}
+
+func TestListenURIFD(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f, err := ln.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// ListenURI takes ownership of the file descriptor, so give it a copy.
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri := "fd://" + strconv.Itoa(fd)
+	adopted, err := ListenURI(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("ListenURI(%s): %v", uri, err)
+	}
+	defer adopted.Close()
+
+	if want, have := ln.Addr().String(), adopted.Addr().String(); want != have {
+		t.Errorf("%s: want %s, have %s", uri, want, have)
+	}
+	checkAccept(t, adopted)
+
+	for _, uri := range []string{"fd://x", "fd://-1"} {
+		if _, err := ListenURI(context.Background(), uri); err == nil {
+			t.Errorf("ListenURI(%s): want error, have none", uri)
+		}
+	}
+}
+
+func checkAccept(t *testing.T, ln net.Listener) {
+	t.Helper()
+
+	errc := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		errc <- err
+	}()
+
+	conn, err := net.Dial(ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial %s: %v", ln.Addr(), err)
+	}
+	conn.Close()
+
+	if err := <-errc; err != nil {
+		t.Fatalf("accept %s: %v", ln.Addr(), err)
+	}
+}
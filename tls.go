@@ -0,0 +1,132 @@
+package unixtransport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// tlsNetworkPrefix marks listen URIs like "tls+tcp://" and "tls+unix://",
+// whose listeners are wrapped with TLS.
+const tlsNetworkPrefix = "tls+"
+
+// splitTLSNetwork strips the TLS prefix from network, and reports whether it
+// was present.
+func splitTLSNetwork(network string) (string, bool) {
+	if strings.HasPrefix(network, tlsNetworkPrefix) {
+		return strings.TrimPrefix(network, tlsNetworkPrefix), true
+	}
+	return network, false
+}
+
+// tlsConfig returns a server TLS config with the configured certificate and
+// key, which are reloaded when they change on disk, and which requires and
+// verifies client certificates if a client CA is configured. It returns nil if
+// TLS isn't configured.
+func (o listenOptions) tlsConfig() (*tls.Config, error) {
+	if !o.tls {
+		return nil, nil
+	}
+
+	reloader, err := newCertReloader(o.certFile, o.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		GetCertificate: reloader.getCertificate,
+	}
+
+	if o.clientCAFile != "" {
+		buf, err := os.ReadFile(o.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("load client CA: no certificates found in %s", o.clientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// certReloader serves a certificate and key from files, and reloads them when
+// either file changes. Rather than watching the files, it checks them with a
+// stat at the start of each handshake, which is cheap compared to the
+// handshake itself, and works the same on every platform.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mtx      sync.Mutex
+	cert     *tls.Certificate
+	certInfo os.FileInfo
+	keyInfo  os.FileInfo
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// getCertificate implements tls.Config.GetCertificate. If reloading a changed
+// certificate fails, e.g. because the key file hasn't been replaced yet, it
+// keeps serving the previous certificate, and tries again on the next
+// handshake.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	_ = r.reload()
+
+	return r.cert, nil
+}
+
+// reload loads the certificate and key, if either file changed since the last
+// successful load. The caller must hold the mutex, or have exclusive access.
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	if r.cert != nil && !fileChanged(r.certInfo, certInfo) && !fileChanged(r.keyInfo, keyInfo) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	r.cert, r.certInfo, r.keyInfo = &cert, certInfo, keyInfo
+
+	return nil
+}
+
+// fileChanged returns true if b describes a different file than a, or the same
+// file with different contents, as far as can be told from a stat.
+func fileChanged(a, b os.FileInfo) bool {
+	return !os.SameFile(a, b) || !a.ModTime().Equal(b.ModTime()) || a.Size() != b.Size()
+}
@@ -0,0 +1,330 @@
+package unixtransport_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestListenURITLS(t *testing.T) {
+	t.Parallel()
+
+	var (
+		tempdir = t.TempDir()
+		cert    = newTestCert(t, tempdir, "server", nil)
+		socket  = filepath.Join(tempdir, "tls.sock")
+		query   = "?cert=" + cert.certFile + "&key=" + cert.keyFile
+	)
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: cert.pool(), ServerName: "localhost"}}
+	unixtransport.Register(transport)
+	client := &http.Client{Transport: transport}
+
+	for _, testcase := range []struct {
+		name   string
+		uri    string
+		rawurl func(net.Addr) string
+	}{
+		{
+			name:   "tcp",
+			uri:    "tls+tcp://127.0.0.1:0" + query,
+			rawurl: func(addr net.Addr) string { return "https://" + addr.String() + "/foo" },
+		},
+		{
+			name:   "unix",
+			uri:    "tls+unix://" + socket + query,
+			rawurl: func(addr net.Addr) string { return "https+unix://" + addr.String() + ":/foo" },
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			ln, err := unixtransport.ListenURI(context.Background(), testcase.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			serveTLS(t, ln)
+
+			var (
+				rawurl = testcase.rawurl(ln.Addr())
+				want   = "TLS /foo"
+				have   = get(t, client, rawurl)
+			)
+			if want != have {
+				t.Errorf("%s: want %q, have %q", rawurl, want, have)
+			}
+		})
+	}
+}
+
+func TestListenURITLSClientCA(t *testing.T) {
+	t.Parallel()
+
+	var (
+		tempdir    = t.TempDir()
+		serverCert = newTestCert(t, tempdir, "server", nil)
+		clientCA   = newTestCert(t, tempdir, "client-ca", nil)
+		clientCert = newTestCert(t, tempdir, "client", clientCA)
+		socket     = filepath.Join(tempdir, "mtls.sock")
+		uri        = "tls+unix://" + socket + "?cert=" + serverCert.certFile + "&key=" + serverCert.keyFile + "&client-ca=" + clientCA.certFile
+		rawurl     = "https+unix://" + socket + ":/bar"
+	)
+
+	ln, err := unixtransport.ListenURI(context.Background(), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serveTLS(t, ln)
+
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: serverCert.pool(), ServerName: "localhost", Certificates: certs}}
+		unixtransport.Register(transport)
+		return &http.Client{Transport: transport}
+	}
+
+	// Without a client certificate, the request should fail.
+	if resp, err := newClient().Get(rawurl); err == nil {
+		resp.Body.Close()
+		t.Errorf("GET %s without client certificate: want error, have none", rawurl)
+	}
+
+	// With a client certificate signed by the client CA, it should succeed.
+	{
+		var (
+			want = "TLS /bar"
+			have = get(t, newClient(clientCert.keyPair(t)), rawurl)
+		)
+		if want != have {
+			t.Errorf("%s: want %q, have %q", rawurl, want, have)
+		}
+	}
+}
+
+func TestListenURITLSReload(t *testing.T) {
+	t.Parallel()
+
+	var (
+		tempdir = t.TempDir()
+		cert1   = newTestCert(t, tempdir, "server-1", nil)
+		cert2   = newTestCert(t, tempdir, "server-2", nil)
+		cert3   = newTestCert(t, tempdir, "server-3", nil)
+		socket  = filepath.Join(tempdir, "tls.sock")
+		uri     = "tls+unix://" + socket + "?cert=" + cert1.certFile + "&key=" + cert1.keyFile
+	)
+
+	ln, err := unixtransport.ListenURI(context.Background(), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serveTLS(t, ln)
+
+	pool := x509.NewCertPool()
+	for _, tc := range []*testCert{cert1, cert2, cert3} {
+		pool.AddCert(tc.cert)
+	}
+
+	checkServerName := func(want string) {
+		t.Helper()
+
+		conn, err := tls.Dial("unix", socket, &tls.Config{RootCAs: pool, ServerName: "localhost"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if have := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; want != have {
+			t.Errorf("server certificate: want %q, have %q", want, have)
+		}
+	}
+
+	rename := func(oldpath, newpath string) {
+		t.Helper()
+
+		if err := os.Rename(oldpath, newpath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkServerName("server-1")
+
+	// Replace both files, the way most certificate tools do.
+	rename(cert2.keyFile, cert1.keyFile)
+	rename(cert2.certFile, cert1.certFile)
+	checkServerName("server-2")
+
+	// While only the certificate has been replaced, it doesn't match the key,
+	// so the previous certificate should still be served.
+	rename(cert3.certFile, cert1.certFile)
+	checkServerName("server-2")
+
+	// Once the key has been replaced too, the new certificate should be served.
+	rename(cert3.keyFile, cert1.keyFile)
+	checkServerName("server-3")
+}
+
+func TestListenURITLSErrors(t *testing.T) {
+	t.Parallel()
+
+	var (
+		tempdir = t.TempDir()
+		cert    = newTestCert(t, tempdir, "server", nil)
+		socket  = filepath.Join(tempdir, "tls.sock")
+	)
+
+	for _, uri := range []string{
+		"tls+tcp://127.0.0.1:0",
+		"tls+tcp://127.0.0.1:0?cert=" + cert.certFile,
+		"tls+unix://" + socket + "?cert=" + cert.certFile + "&key=" + filepath.Join(tempdir, "missing.pem"),
+		"tls+unix://" + socket + "?cert=" + cert.certFile + "&key=" + cert.keyFile + "&client-ca=" + cert.keyFile,
+		"tcp://127.0.0.1:0?cert=" + cert.certFile + "&key=" + cert.keyFile,
+	} {
+		t.Run(uri, func(t *testing.T) {
+			ln, err := unixtransport.ListenURI(context.Background(), uri)
+			if err == nil {
+				ln.Close()
+				t.Fatalf("want error, have none")
+			}
+
+			if _, err := os.Stat(socket); !os.IsNotExist(err) {
+				t.Errorf("socket file: want not exist, have %v", err)
+			}
+		})
+	}
+}
+
+// serveTLS serves HTTP on ln until the test is done. The handler responds with
+// "TLS" and the request path, if the request came in over TLS.
+func serveTLS(t *testing.T, ln net.Listener) {
+	t.Helper()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			http.Error(w, "not TLS", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "TLS", r.URL.Path)
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = ln
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Start()
+	t.Cleanup(server.Close)
+}
+
+// testCert is a certificate and private key, written to PEM files.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert creates a certificate for "localhost" and 127.0.0.1 with the
+// given common name, which is signed by parent, or self-signed if parent is
+// nil. Self-signed certificates can sign other certificates. The PEM files are
+// written to dir, and named after the common name.
+func newTestCert(t *testing.T, dir, commonName string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	} else {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, commonName+".pem"),
+		keyFile:  filepath.Join(dir, commonName+"-key.pem"),
+	}
+
+	writePEM(t, tc.certFile, "CERTIFICATE", der)
+	writePEM(t, tc.keyFile, "EC PRIVATE KEY", keyDER)
+
+	return tc
+}
+
+func (tc *testCert) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(tc.cert)
+	return pool
+}
+
+func (tc *testCert) keyPair(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair(tc.certFile, tc.keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	buf := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
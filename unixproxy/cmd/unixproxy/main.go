@@ -12,6 +12,7 @@ import (
 	"os"
 	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"github.com/oklog/run"
 	"github.com/peterbourgon/ff/v3"
@@ -44,10 +45,11 @@ func main() {
 func exe(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
 	fs := flag.NewFlagSet("unixproxy", flag.ContinueOnError)
 	var (
-		addrFlag = fs.String("addr", ":80", "listen address for HTTP reverse proxy server")
-		hostFlag = fs.String("host", "unixproxy.localhost", "Host header where this service is reachable")
-		rootFlag = fs.String("root", ".", "root path to look for Unix sockets")
-		dnsFlag  = fs.String("dns", "", "listen address for optional local DNS resolver (e.g. ':5354')")
+		addrFlag   = fs.String("addr", ":80", "listen address for HTTP reverse proxy server")
+		hostFlag   = fs.String("host", "unixproxy.localhost", "Host header where this service is reachable")
+		rootFlag   = fs.String("root", ".", "root path to look for Unix sockets")
+		dnsFlag    = fs.String("dns", "", "listen address for optional local DNS resolver (e.g. ':5354')")
+		healthFlag = fs.String("health-path", "", "path to GET from each socket to check its health in the index (e.g. '/healthz')")
 	)
 	fs.Usage = usageFor(fs)
 	if err := ff.Parse(fs, args); err != nil {
@@ -61,10 +63,18 @@ func exe(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args []
 		return fmt.Errorf("listen on proxy addr: %w", err)
 	}
 
+	registry, err := unixproxy.NewRegistry(*rootFlag)
+	if err != nil {
+		return fmt.Errorf("watch sockets root: %w", err)
+	}
+	defer registry.Close()
+
 	proxyHandler := &unixproxy.Handler{
-		Host:           *hostFlag,
-		Root:           *rootFlag,
-		ErrorLogWriter: logger.Writer(),
+		Host:            *hostFlag,
+		Root:            *rootFlag,
+		ErrorLogWriter:  logger.Writer(),
+		HealthCheckPath: *healthFlag,
+		Registry:        registry,
 	}
 
 	logger.Printf("serving host http://%s", *hostFlag)
@@ -74,7 +84,14 @@ func exe(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args []
 
 	{
 		logger.Printf("proxy listening on %s", proxyListener.Addr())
-		server := &http.Server{Handler: proxyHandler}
+		server := &http.Server{
+			Handler:           proxyHandler,
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			// No WriteTimeout, which would cut off long responses, and the
+			// index event stream. None of the timeouts apply to upgraded
+			// connections, like WebSockets.
+		}
 		g.Add(func() error {
 			return server.Serve(proxyListener)
 		}, func(error) {
@@ -17,7 +17,8 @@
 //
 // The intermediating reverse-proxy, provided by [Handler], works dynamically,
 // without any explicit configuration. See documentation on that type for usage
-// information.
+// information. A [Registry] can watch the sockets for the Handler, rather than
+// having it check the filesystem on every request.
 //
 // cmd/unixproxy is an example program utilizing package unixproxy.
 package unixproxy
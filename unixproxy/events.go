@@ -0,0 +1,136 @@
+package unixproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// socketsPollInterval is how often the sockets are checked for changes,
+	// for live updates, if the Handler doesn't have a Registry.
+	socketsPollInterval = time.Second
+
+	// eventsKeepaliveInterval is how often a comment is sent to idle event
+	// streams, so that intermediaries don't close them.
+	eventsKeepaliveInterval = 15 * time.Second
+)
+
+// handleEvents streams the index as server-sent events, initially, and
+// whenever a socket is added or removed. Each event has type "entries", and the
+// index entries as a JSON array for its data.
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventsKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for updates := h.socketUpdates(r.Context()); ; {
+		select {
+		case paths, ok := <-updates:
+			if !ok {
+				return
+			}
+			buf, _ := json.Marshal(h.indexEntries(r.Context(), paths))
+			fmt.Fprintf(w, "event: entries\ndata: %s\n\n", buf)
+
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+
+		case <-r.Context().Done():
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+// handleIndexWatch streams the index as newline-delimited JSON, initially, and
+// whenever a socket is added or removed. Each line is a JSON array of index
+// entries.
+func (h *Handler) handleIndexWatch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/x-ndjson; charset=utf-8")
+	w.Header().Set("cache-control", "no-cache")
+
+	enc := json.NewEncoder(w)
+	for paths := range h.socketUpdates(r.Context()) {
+		enc.Encode(h.indexEntries(r.Context(), paths))
+		flusher.Flush()
+	}
+}
+
+// socketUpdates returns a channel which receives the paths of the current
+// sockets, and then every changed list, until the context is canceled. Changes
+// are detected via the Registry, if there is one, or by polling.
+func (h *Handler) socketUpdates(ctx context.Context) <-chan []string {
+	updates := make(chan []string)
+
+	go func() {
+		defer close(updates)
+
+		var events <-chan Event
+		var poll <-chan time.Time
+		if h.Registry != nil {
+			c, cancel := h.Registry.Subscribe()
+			defer cancel()
+			events = c
+		} else {
+			ticker := time.NewTicker(socketsPollInterval)
+			defer ticker.Stop()
+			poll = ticker.C
+		}
+
+		var prev []string
+		for first := true; ; first = false {
+			if paths, err := h.sockets(); err == nil && (first || !equalStrings(prev, paths)) {
+				select {
+				case updates <- paths:
+					prev = paths
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return // the registry was closed
+				}
+			case <-poll:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package unixproxy_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/unixtransport/unixproxy"
+)
+
+func TestHandlerEvents(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+
+	registry, err := unixproxy.NewRegistry(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	proxy := httptest.NewServer(&unixproxy.Handler{Host: "unixproxy.localhost", Registry: registry})
+	defer proxy.Close()
+
+	resp := testStreamRequest(t, proxy, "/events", "text/event-stream")
+	defer resp.Body.Close()
+
+	if want, have := "text/event-stream", resp.Header.Get("content-type"); want != have {
+		t.Errorf("content-type: want %q, have %q", want, have)
+	}
+
+	events := bufio.NewReader(resp.Body)
+
+	if want, have := []string{}, eventDomains(t, readEventData(t, events)); !reflect.DeepEqual(want, have) {
+		t.Errorf("initial event: want %q, have %q", want, have)
+	}
+
+	ln := listenSocket(t, filepath.Join(root, "foo"))
+
+	if want, have := []string{"foo.unixproxy.localhost"}, eventDomains(t, readEventData(t, events)); !reflect.DeepEqual(want, have) {
+		t.Errorf("after listen: want %q, have %q", want, have)
+	}
+
+	ln.Close()
+
+	if want, have := []string{}, eventDomains(t, readEventData(t, events)); !reflect.DeepEqual(want, have) {
+		t.Errorf("after close: want %q, have %q", want, have)
+	}
+}
+
+func TestHandlerIndexWatch(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+
+	// Without a registry, the handler polls for changes.
+	proxy := httptest.NewServer(&unixproxy.Handler{Host: "unixproxy.localhost", Root: root})
+	defer proxy.Close()
+
+	resp := testStreamRequest(t, proxy, "/?watch=true", "application/json")
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+
+	readLine := func() string {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("read line: %v", lines.Err())
+		}
+		return lines.Text()
+	}
+
+	if want, have := []string{}, eventDomains(t, readLine()); !reflect.DeepEqual(want, have) {
+		t.Errorf("initial line: want %q, have %q", want, have)
+	}
+
+	listenSocket(t, filepath.Join(root, "foo"))
+
+	if want, have := []string{"foo.unixproxy.localhost"}, eventDomains(t, readLine()); !reflect.DeepEqual(want, have) {
+		t.Errorf("after listen: want %q, have %q", want, have)
+	}
+}
+
+// testStreamRequest makes a streaming request to the index of the proxy, which
+// is canceled when the test is done, or after a generous timeout.
+func testStreamRequest(t *testing.T, proxy *httptest.Server, path, accept string) *http.Response {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", proxy.URL+path, nil)
+	req.Host = "unixproxy.localhost"
+	req.Header.Set("accept", accept)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Fatalf("GET %s: want %d, have %d", path, want, have)
+	}
+
+	return resp
+}
+
+// readEventData reads server-sent events until an "entries" event, and returns
+// its data.
+func readEventData(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+
+		switch line = strings.TrimSuffix(line, "\n"); {
+		case line == "" && event == "entries":
+			return data
+		case line == "":
+			event, data = "", ""
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// eventDomains decodes a JSON array of index entries, and returns their
+// domains.
+func eventDomains(t *testing.T, data string) []string {
+	t.Helper()
+
+	var entries []struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		t.Fatalf("decode %q: %v", data, err)
+	}
+
+	domains := []string{}
+	for _, e := range entries {
+		domains = append(domains, e.Domain)
+	}
+
+	return domains
+}
@@ -7,15 +7,14 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"log"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
-	"text/template"
+	"time"
 )
 
 // Handler is a reverse proxy to Unix sockets on the local filesystem.
@@ -29,6 +28,12 @@ import (
 // "/tmp/abc" would map a request with Host header "foo.bar.unixproxy.localhost"
 // to a socket at "/tmp/abc/foo/bar".
 //
+// Requests to upgrade the connection, e.g. to WebSockets, are proxied too. Once
+// the backend switches protocols, bytes are copied in both directions until
+// both sides are done, and a half-close by either side is passed on to the
+// other. The server's ReadTimeout, WriteTimeout, and IdleTimeout don't apply
+// to upgraded connections, so they can be idle indefinitely.
+//
 // Parameters are evaluated during ServeHTTP.
 type Handler struct {
 	// Root is a valid directory on the local filesystem. The handler will look
@@ -52,12 +57,28 @@ type Handler struct {
 	Host string
 
 	// ErrorLogWriter is used as the destination writer for the ErrorLog of the
-	// [http.ReverseProxy] used to proxy individual requests.
+	// [http.ReverseProxy] used to proxy requests to each socket.
 	//
 	// Optional. By default, each [http.ReverseProxy] has a nil ErrorLog.
 	ErrorLogWriter io.Writer
 
-	once sync.Once
+	// HealthCheckPath is requested with GET from every socket listed in the
+	// index, e.g. "/healthz", and the response status is reported alongside
+	// the result of dialing the socket.
+	//
+	// Optional. By default, sockets in the index are only dialed.
+	HealthCheckPath string
+
+	// Registry is consulted for the sockets under Root, by both the index and
+	// the proxy, instead of the filesystem. It should have the same root
+	// directory as the Handler. If Root isn't set, the Registry's root is used.
+	//
+	// Optional. By default, the index walks Root on every request, and the
+	// proxy periodically checks that each socket still exists.
+	Registry *Registry
+
+	once    sync.Once
+	proxies proxyCache
 }
 
 const defaultHost = "unixproxy.localhost"
@@ -68,6 +89,9 @@ func (h *Handler) validate() error {
 		if h.Host == "" {
 			h.Host = defaultHost
 		}
+		if h.Root == "" && h.Registry != nil {
+			h.Root = h.Registry.Root()
+		}
 	})
 
 	if h.Root == "" {
@@ -87,6 +111,12 @@ func (h *Handler) validate() error {
 // Host field (i.e. has no subdomains), ServeHTTP will serve a list of valid
 // subdomains. Otherwise, the request will be proxied to a local Unix domain
 // socket based on its subdomain.
+//
+// The list of subdomains is served as HTML, JSON, or plain text, depending on
+// the Accept header. The HTML page updates itself as sockets come and go, via
+// server-sent events from the path /events, which stream the list as a JSON
+// array whenever it changes. JSON requests with the query parameter
+// "watch=true" get a similar stream, as newline-delimited JSON.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := h.validate(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -98,6 +128,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.URL.Path == "/favicon.ico":
 		http.NotFound(w, r)
+	case normalizedHost == h.Host && r.URL.Path == "/events":
+		h.handleEvents(w, r)
 	case normalizedHost == h.Host:
 		h.handleIndex(w, r)
 	default:
@@ -106,7 +138,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
-	domains, err := h.domains()
+	paths, err := h.sockets()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -116,29 +148,38 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case strings.Contains(accept, "text/html"):
 		var buf bytes.Buffer
-		if err := indexTemplate.Execute(&buf, struct{ Domains []string }{domains}); err != nil {
+		if err := indexTemplate.Execute(&buf, struct{ Entries []indexEntry }{h.indexEntries(r.Context(), paths)}); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("content-type", "text/html; charset=utf-8")
 		buf.WriteTo(w)
 
+	case strings.Contains(accept, "application/json") && isTrue(r.URL.Query().Get("watch")):
+		h.handleIndexWatch(w, r)
+
 	case strings.Contains(accept, "application/json"):
 		w.Header().Set("content-type", "application/json; charset=utf-8")
 		enc := json.NewEncoder(w)
 		enc.SetIndent("", "    ")
-		enc.Encode(domains)
+		enc.Encode(h.indexEntries(r.Context(), paths))
 
 	default:
 		w.Header().Set("content-type", "text/plain; charset=utf-8")
-		for _, s := range domains {
-			fmt.Fprintln(w, s)
+		for _, path := range paths {
+			fmt.Fprintln(w, h.domain(path))
 		}
 	}
 }
 
-func (h *Handler) domains() ([]string, error) {
-	var domains []string
+// sockets returns the paths of all sockets under Root, from the Registry if
+// there is one, or else by walking Root.
+func (h *Handler) sockets() ([]string, error) {
+	if h.Registry != nil {
+		return h.Registry.Sockets(), nil
+	}
+
+	var paths []string
 	if err := filepath.WalkDir(h.Root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -148,19 +189,24 @@ func (h *Handler) domains() ([]string, error) {
 			return nil
 		}
 
-		relpath, err := filepath.Rel(h.Root, path)
-		if err != nil {
-			return err
-		}
-
-		subdomain := strings.Replace(relpath, string(filepath.Separator), ".", -1)
-		domain := strings.Trim(subdomain, ".") + "." + strings.Trim(h.Host, ".")
-		domains = append(domains, domain)
+		paths = append(paths, path)
 		return nil
 	}); err != nil {
 		return nil, err
 	}
-	return domains, nil
+	return paths, nil
+}
+
+// domain returns the domain which is proxied to the socket at path, which must
+// be under Root.
+func (h *Handler) domain(path string) string {
+	relpath, err := filepath.Rel(h.Root, path)
+	if err != nil {
+		relpath = path
+	}
+
+	subdomain := strings.Replace(relpath, string(filepath.Separator), ".", -1)
+	return strings.Trim(subdomain, ".") + "." + strings.Trim(h.Host, ".")
 }
 
 func (h *Handler) handleProxy(w http.ResponseWriter, r *http.Request) {
@@ -173,32 +219,20 @@ func (h *Handler) handleProxy(w http.ResponseWriter, r *http.Request) {
 		socketPath     = filepath.Join(h.Root, relativePath)
 	)
 
-	fi, err := os.Stat(socketPath) // TODO: chroot?
-	if err != nil || fi.Mode()&os.ModeSocket == 0 {
-		http.Error(w, fmt.Sprintf("target socket %s invalid", socketPath), http.StatusNotFound)
+	rp, err := h.proxies.get(socketPath, relativePath, h.ErrorLogWriter, h.Registry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	director := func(req *http.Request) {
-		req.URL.Scheme = "http"
-		req.URL.Host = socketPath
-		req.URL.Path = r.URL.Path
-	}
-
-	var proxyLog *log.Logger
-	if h.ErrorLogWriter != nil {
-		proxyLog = log.New(h.ErrorLogWriter, fmt.Sprintf("unixproxy: %s: ", relativePath), 0)
-	}
-
-	rp := &httputil.ReverseProxy{
-		Transport: onlyUnixTransport,
-		ErrorLog:  proxyLog,
-		Director:  director,
-	}
-
 	rp.ServeHTTP(w, r)
 }
 
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
+
 func normalizeHost(host string) string {
 	// Strip any :port suffix.
 	if h, _, err := net.SplitHostPort(host); err == nil {
@@ -213,28 +247,19 @@ func normalizeHost(host string) string {
 	return strings.ToLower(host)
 }
 
+// onlyUnixTransport dials the Unix socket named by the request URL host. Its
+// timeouts only apply to establishing connections, and to idle connections in
+// the pool. Upgraded connections leave the pool, so they can stay idle for as
+// long as the client and backend like. The dialed connections support
+// CloseWrite, so a half-close by the client of an upgraded connection reaches
+// the backend, and vice versa.
 var onlyUnixTransport = &http.Transport{
 	DialContext: func(ctx context.Context, _, address string) (net.Conn, error) {
 		host, _, err := net.SplitHostPort(address)
 		if err == nil {
 			address = host
 		}
-		return (&net.Dialer{}).DialContext(ctx, "unix", address)
+		return (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, "unix", address)
 	},
+	IdleConnTimeout: 90 * time.Second,
 }
-
-var indexTemplate = template.Must(template.New("").Parse(`
-<!DOCTYPE html>
-<html lang="en">
-<head>
-<title>unixproxy</title>
-</head>
-<body>
-<ul>
-{{ range .Domains -}}
-<li><a href="//{{.}}">{{.}}</a></li>
-{{ else -}}
-<li>No active sockets found</li>
-{{ end -}}
-</ul>
-`))
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -38,6 +39,52 @@ func TestHandlerBasic(t *testing.T) {
 	}
 }
 
+func TestHandlerRegistry(t *testing.T) {
+	root := t.TempDir()
+
+	registry, err := unixproxy.NewRegistry(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	events, cancel := registry.Subscribe()
+	defer cancel()
+
+	// Root is taken from the registry.
+	proxy := httptest.NewServer(&unixproxy.Handler{Host: "unixproxy.localhost", Registry: registry})
+	defer proxy.Close()
+
+	if want, have := "", testBasicRequest(t, proxy, "unixproxy.localhost"); want != have {
+		t.Errorf("GET unixproxy.localhost: want %q, have %q", want, have)
+	}
+
+	path := filepath.Join(root, "foo")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "hello from foo")
+	}))
+	server.Listener = listenSocket(t, path)
+	server.Start()
+	defer server.Close()
+
+	waitEvent(t, events, unixproxy.Event{Op: unixproxy.SocketAdded, Path: path})
+
+	if want, have := "foo.unixproxy.localhost", testBasicRequest(t, proxy, "unixproxy.localhost"); want != have {
+		t.Errorf("GET unixproxy.localhost: want %q, have %q", want, have)
+	}
+
+	if want, have := "hello from foo", testBasicRequest(t, proxy, "foo.unixproxy.localhost"); want != have {
+		t.Errorf("GET foo.unixproxy.localhost: want %q, have %q", want, have)
+	}
+
+	server.Close()
+	waitEvent(t, events, unixproxy.Event{Op: unixproxy.SocketRemoved, Path: path})
+
+	if want, have := "target socket "+path+" invalid", testBasicRequest(t, proxy, "foo.unixproxy.localhost"); want != have {
+		t.Errorf("GET foo.unixproxy.localhost: want %q, have %q", want, have)
+	}
+}
+
 func testHandlerServer(t *testing.T, ctx context.Context) (*httptest.Server, func()) {
 	t.Helper()
 
@@ -95,3 +142,37 @@ func testBasicRequest(t *testing.T, proxy *httptest.Server, host string) string
 
 	return strings.TrimSpace(string(body))
 }
+
+func BenchmarkHandlerProxy(b *testing.B) {
+	root := b.TempDir()
+
+	listener, err := unixtransport.ListenURI(context.Background(), "unix://"+root+"/foo")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "hello from foo")
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	handler := &unixproxy.Handler{
+		Host:           "unixproxy.localhost",
+		Root:           root,
+		ErrorLogWriter: io.Discard,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("GET", "http://foo.unixproxy.localhost/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			b.Fatalf("status %d", rec.Code)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package unixproxy
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds how long each socket is probed for the index.
+var healthCheckTimeout = time.Second
+
+// indexEntry describes a socket in the index.
+type indexEntry struct {
+	Domain  string       `json:"domain"`
+	Socket  string       `json:"socket"`
+	Owner   string       `json:"owner,omitempty"`
+	Mode    string       `json:"mode"`
+	ModTime time.Time    `json:"mtime"`
+	Health  socketHealth `json:"health"`
+}
+
+// socketHealth is the result of probing a socket. Latency covers dialing the
+// socket, and the health check request, if there is one.
+type socketHealth struct {
+	Reachable bool    `json:"reachable"`
+	Status    int     `json:"status,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// indexEntries describes the sockets at paths, probing them concurrently.
+// Sockets which disappear in the meantime are skipped.
+func (h *Handler) indexEntries(ctx context.Context, paths []string) []indexEntry {
+	var (
+		entries = make([]indexEntry, len(paths))
+		exists  = make([]bool, len(paths))
+		wg      sync.WaitGroup
+	)
+
+	for i, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		entries[i] = indexEntry{
+			Domain:  h.domain(path),
+			Socket:  path,
+			Owner:   fileOwner(fi),
+			Mode:    fi.Mode().String(),
+			ModTime: fi.ModTime(),
+		}
+		exists[i] = true
+
+		wg.Add(1)
+		go func(e *indexEntry) {
+			defer wg.Done()
+			e.Health = h.probe(ctx, e.Socket, e.Domain)
+		}(&entries[i])
+	}
+
+	wg.Wait()
+
+	result := []indexEntry{} // so it's encoded as an empty list
+	for i := range entries {
+		if exists[i] {
+			result = append(result, entries[i])
+		}
+	}
+
+	return result
+}
+
+// probe dials the socket at path, and then requests HealthCheckPath from it,
+// if it's set, with the domain as the Host header.
+func (h *Handler) probe(ctx context.Context, path, domain string) socketHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	begin := time.Now()
+	latency := func() float64 { return float64(time.Since(begin).Microseconds()) / 1000 }
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unix", path)
+	if err != nil {
+		return socketHealth{Error: err.Error(), LatencyMS: latency()}
+	}
+	conn.Close()
+
+	if h.HealthCheckPath == "" {
+		return socketHealth{Reachable: true, LatencyMS: latency()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+domain+h.HealthCheckPath, nil)
+	if err != nil {
+		return socketHealth{Reachable: true, Error: err.Error(), LatencyMS: latency()}
+	}
+	req.URL.Host = path // dialed by onlyUnixTransport
+	req.Close = true    // don't pool connections to every socket for every probe
+
+	resp, err := onlyUnixTransport.RoundTrip(req)
+	if err != nil {
+		return socketHealth{Reachable: true, Error: err.Error(), LatencyMS: latency()}
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	return socketHealth{Reachable: true, Status: resp.StatusCode, LatencyMS: latency()}
+}
+
+var indexTemplate = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<title>unixproxy</title>
+</head>
+<body>
+<table>
+<thead>
+<tr><th>Domain</th><th>Socket</th><th>Owner</th><th>Mode</th><th>Modified</th><th>Health</th></tr>
+</thead>
+<tbody id="entries">
+{{ range .Entries -}}
+<tr>
+<td><a href="//{{.Domain}}">{{.Domain}}</a></td>
+<td>{{.Socket}}</td>
+<td>{{.Owner}}</td>
+<td>{{.Mode}}</td>
+<td>{{.ModTime.Format "2006-01-02T15:04:05Z07:00"}}</td>
+<td title="{{.Health.Error}}">{{ if .Health.Reachable }}{{ with .Health.Status }}{{.}}{{ else }}ok{{ end }} {{ printf "%.1fms" .Health.LatencyMS }}{{ else }}unreachable{{ end }}</td>
+</tr>
+{{ else -}}
+<tr><td colspan="6">No active sockets found</td></tr>
+{{ end -}}
+</tbody>
+</table>
+<script>
+const tbody = document.getElementById("entries");
+const cell = (text) => {
+	const td = document.createElement("td");
+	td.textContent = text;
+	return td;
+};
+new EventSource("/events").addEventListener("entries", (event) => {
+	const rows = JSON.parse(event.data).map((entry) => {
+		const a = document.createElement("a");
+		a.href = "//" + entry.domain;
+		a.textContent = entry.domain;
+		const domain = cell("");
+		domain.append(a);
+		const h = entry.health;
+		const health = cell(h.reachable ? (h.status || "ok") + " " + h.latency_ms.toFixed(1) + "ms" : "unreachable");
+		health.title = h.error || "";
+		const tr = document.createElement("tr");
+		tr.append(domain, cell(entry.socket), cell(entry.owner || ""), cell(entry.mode), cell(entry.mtime.replace(/\.\d+/, "")), health);
+		return tr;
+	});
+	if (rows.length === 0) {
+		const td = cell("No active sockets found");
+		td.colSpan = 6;
+		const tr = document.createElement("tr");
+		tr.append(td);
+		rows.push(tr);
+	}
+	tbody.replaceChildren(...rows);
+});
+</script>
+`))
@@ -0,0 +1,149 @@
+package unixproxy_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/unixtransport/unixproxy"
+)
+
+func TestHandlerIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+
+	// foo is healthy. Probes shouldn't leave idle connections behind, so they
+	// must ask for the connection to be closed.
+	fooPath := filepath.Join(root, "foo")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" && r.Host == "foo.unixproxy.localhost" && r.Close {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	server.Listener = listenSocket(t, fooPath)
+	server.Start()
+	defer server.Close()
+
+	// bar is a stale socket, with nothing listening.
+	barPath := filepath.Join(root, "bar")
+	{
+		ln, err := net.Listen("unix", barPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln.(*net.UnixListener).SetUnlinkOnClose(false)
+		ln.Close()
+	}
+
+	proxy := httptest.NewServer(&unixproxy.Handler{
+		Host:            "unixproxy.localhost",
+		Root:            root,
+		HealthCheckPath: "/healthz",
+	})
+	defer proxy.Close()
+
+	t.Run("json", func(t *testing.T) {
+		var entries []struct {
+			Domain  string    `json:"domain"`
+			Socket  string    `json:"socket"`
+			Owner   string    `json:"owner"`
+			Mode    string    `json:"mode"`
+			ModTime time.Time `json:"mtime"`
+			Health  struct {
+				Reachable bool    `json:"reachable"`
+				Status    int     `json:"status"`
+				LatencyMS float64 `json:"latency_ms"`
+				Error     string  `json:"error"`
+			} `json:"health"`
+		}
+		if err := json.Unmarshal([]byte(testIndexRequest(t, proxy, "application/json")), &entries); err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := 2, len(entries); want != have {
+			t.Fatalf("entries: want %d, have %d", want, have)
+		}
+
+		bar, foo := entries[0], entries[1] // lexical order
+
+		if want, have := "foo.unixproxy.localhost", foo.Domain; want != have {
+			t.Errorf("foo domain: want %q, have %q", want, have)
+		}
+		if want, have := fooPath, foo.Socket; want != have {
+			t.Errorf("foo socket: want %q, have %q", want, have)
+		}
+		if current, err := user.Current(); err == nil {
+			if want, have := current.Username, foo.Owner; want != have {
+				t.Errorf("foo owner: want %q, have %q", want, have)
+			}
+		}
+		if !strings.HasPrefix(foo.Mode, "S") {
+			t.Errorf("foo mode: want socket, have %q", foo.Mode)
+		}
+		if foo.ModTime.IsZero() || time.Since(foo.ModTime) > time.Minute {
+			t.Errorf("foo mtime: want recent, have %s", foo.ModTime)
+		}
+		if !foo.Health.Reachable || foo.Health.Status != http.StatusNoContent || foo.Health.Error != "" {
+			t.Errorf("foo health: want reachable with status 204, have %+v", foo.Health)
+		}
+
+		if want, have := "bar.unixproxy.localhost", bar.Domain; want != have {
+			t.Errorf("bar domain: want %q, have %q", want, have)
+		}
+		if bar.Health.Reachable || bar.Health.Status != 0 || bar.Health.Error == "" {
+			t.Errorf("bar health: want unreachable with error, have %+v", bar.Health)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		body := testIndexRequest(t, proxy, "text/html")
+		for _, want := range []string{
+			"<table>",
+			`<a href="//foo.unixproxy.localhost">foo.unixproxy.localhost</a>`,
+			"<td>" + fooPath + "</td>",
+			"204 ",
+			"unreachable",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("HTML index doesn't contain %q", want)
+			}
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		if want, have := "bar.unixproxy.localhost\nfoo.unixproxy.localhost", testIndexRequest(t, proxy, "text/plain"); want != have {
+			t.Errorf("want %q, have %q", want, have)
+		}
+	})
+}
+
+func testIndexRequest(t *testing.T, proxy *httptest.Server, accept string) string {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", proxy.URL, nil)
+	req.Host = "unixproxy.localhost"
+	req.Header.Set("accept", accept)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(body))
+}
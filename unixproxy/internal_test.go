@@ -1,6 +1,12 @@
 package unixproxy
 
-import "testing"
+import (
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
 
 func TestNormalizeHost(t *testing.T) {
 	for _, tc := range []struct {
@@ -27,3 +33,71 @@ func TestNormalizeHost(t *testing.T) {
 		}
 	}
 }
+
+func TestRegistryPolling(t *testing.T) {
+	defer func(prev time.Duration) { registryPollInterval = prev }(registryPollInterval)
+	registryPollInterval = 10 * time.Millisecond
+
+	root := t.TempDir()
+	registry := newRegistry(root, nil)
+	defer registry.Close()
+
+	events, cancel := registry.Subscribe()
+	defer cancel()
+
+	path := filepath.Join(root, "foo")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	select {
+	case e := <-events:
+		if want, have := (Event{Op: SocketAdded, Path: path}), e; want != have {
+			t.Errorf("want %v, have %v", want, have)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+
+	if !registry.Contains(path) {
+		t.Errorf("Contains(%s): want true, have false", path)
+	}
+}
+
+func TestRegistryWatchFailure(t *testing.T) {
+	defer func(prev time.Duration) { registryPollInterval = prev }(registryPollInterval)
+	registryPollInterval = 10 * time.Millisecond
+
+	// A watcher which can't watch anything, e.g. because the inotify watch limit
+	// is exhausted, and so never signals changes.
+	root := t.TempDir()
+	registry := newRegistry(root, failingWatcher{})
+	defer registry.Close()
+
+	events, cancel := registry.Subscribe()
+	defer cancel()
+
+	path := filepath.Join(root, "foo")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	select {
+	case e := <-events:
+		if want, have := (Event{Op: SocketAdded, Path: path}), e; want != have {
+			t.Errorf("want %v, have %v", want, have)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+}
+
+type failingWatcher struct{}
+
+func (failingWatcher) add(string) error         { return syscall.ENOSPC }
+func (failingWatcher) changes() <-chan struct{} { return nil }
+func (failingWatcher) close() error             { return nil }
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package unixproxy
+
+import "os"
+
+// fileOwner isn't supported on this platform.
+func fileOwner(fi os.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,142 @@
+package unixproxy
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"sync"
+	"time"
+)
+
+// socketCheckInterval is how long a cached proxy is used before the socket it
+// targets is checked again, to detect if it has been removed or replaced.
+var socketCheckInterval = time.Second
+
+// proxyCache holds a reverse proxy per socket path, so that proxies, their
+// loggers, and their connection pools are reused across requests. The zero
+// value is ready to use.
+type proxyCache struct {
+	mtx     sync.RWMutex
+	entries map[string]*proxyEntry
+}
+
+type proxyEntry struct {
+	proxy     *httputil.ReverseProxy
+	transport *http.Transport
+	info      os.FileInfo // of the socket, when the entry was created
+	checked   time.Time   // when the socket was last checked
+}
+
+// get returns the proxy for the socket at socketPath. If registry is non-nil,
+// the socket is looked up there on every call. Otherwise, the socket is checked
+// on the filesystem, if it hasn't been checked within socketCheckInterval. If
+// the socket no longer exists, or has been replaced by a different file, the
+// cached proxy is discarded, and its idle connections are closed. Proxy errors
+// are logged to logWriter, if it's non-nil, prefixed with name.
+func (c *proxyCache) get(socketPath, name string, logWriter io.Writer, registry *Registry) (*httputil.ReverseProxy, error) {
+	now := time.Now()
+
+	c.mtx.RLock()
+	e, ok := c.entries[socketPath]
+	valid := ok && e.valid(socketPath, registry, now)
+	c.mtx.RUnlock()
+
+	if valid {
+		return e.proxy, nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// Another request may have checked the socket in the meantime.
+	e, ok = c.entries[socketPath]
+	if ok && e.valid(socketPath, registry, now) {
+		return e.proxy, nil
+	}
+
+	fi, exists := statSocket(socketPath, registry)
+	if !exists {
+		c.evictLocked(socketPath)
+		return nil, fmt.Errorf("target socket %s invalid", socketPath)
+	}
+
+	if ok && sameSocket(e.info, fi) {
+		e.checked = now
+		return e.proxy, nil
+	}
+
+	c.evictLocked(socketPath)
+
+	var proxyLog *log.Logger
+	if logWriter != nil {
+		proxyLog = log.New(logWriter, fmt.Sprintf("unixproxy: %s: ", name), 0)
+	}
+
+	transport := onlyUnixTransport.Clone()
+
+	e = &proxyEntry{
+		proxy: &httputil.ReverseProxy{
+			Transport: transport,
+			ErrorLog:  proxyLog,
+			Director: func(req *http.Request) {
+				req.URL.Scheme = "http"
+				req.URL.Host = socketPath
+			},
+		},
+		transport: transport,
+		info:      fi,
+		checked:   now,
+	}
+
+	if c.entries == nil {
+		c.entries = map[string]*proxyEntry{}
+	}
+	c.entries[socketPath] = e
+
+	return e.proxy, nil
+}
+
+// valid returns true if the entry can be used without checking the socket on
+// the filesystem, because the registry has the same socket, or because the
+// socket was checked recently enough.
+func (e *proxyEntry) valid(socketPath string, registry *Registry, now time.Time) bool {
+	if registry != nil {
+		fi, ok := registry.socket(socketPath)
+		return ok && sameSocket(e.info, fi)
+	}
+	return now.Sub(e.checked) < socketCheckInterval
+}
+
+// statSocket returns the file info of the socket at path, from the registry if
+// it's non-nil, or else from the filesystem. It returns false if there's no
+// socket at path.
+func statSocket(path string, registry *Registry) (os.FileInfo, bool) {
+	if registry != nil {
+		return registry.socket(path)
+	}
+
+	fi, err := os.Stat(path) // TODO: chroot?
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil, false
+	}
+
+	return fi, true
+}
+
+// sameSocket returns true if a and b describe the same socket. Inode numbers
+// are commonly reused right away, so the modification time is compared too.
+func sameSocket(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime())
+}
+
+// evictLocked discards the cached proxy for socketPath, if any, and closes its
+// idle connections. The caller must hold the write lock.
+func (c *proxyCache) evictLocked(socketPath string) {
+	if e, ok := c.entries[socketPath]; ok {
+		e.transport.CloseIdleConnections()
+		delete(c.entries, socketPath)
+	}
+}
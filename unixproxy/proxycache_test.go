@@ -0,0 +1,90 @@
+package unixproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestProxyCache(t *testing.T) {
+	defer func(prev time.Duration) { socketCheckInterval = prev }(socketCheckInterval)
+
+	var (
+		root    = t.TempDir()
+		socket  = filepath.Join(root, "foo")
+		handler = &Handler{Root: root}
+	)
+
+	serve := func(response string) func() {
+		ln, err := unixtransport.ListenURI(context.Background(), "unix://"+socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, response)
+		}))
+		server.Listener = ln
+		server.Start()
+		return server.Close
+	}
+
+	request := func() (int, string) {
+		req := httptest.NewRequest("GET", "http://foo.unixproxy.localhost/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	check := func(wantCode int, wantBody string) {
+		t.Helper()
+		code, body := request()
+		if code != wantCode || (wantBody != "" && body != wantBody) {
+			t.Errorf("want %d %q, have %d %q", wantCode, wantBody, code, body)
+		}
+	}
+
+	cached := func() *proxyEntry {
+		handler.proxies.mtx.RLock()
+		defer handler.proxies.mtx.RUnlock()
+		return handler.proxies.entries[socket]
+	}
+
+	// With a long check interval, the proxy should be created once, and reused.
+	socketCheckInterval = time.Hour
+
+	closeA := serve("A")
+	check(http.StatusOK, "A")
+	first := cached()
+	check(http.StatusOK, "A")
+	if cached() != first {
+		t.Errorf("proxy wasn't reused")
+	}
+
+	// With checks on every request, a replaced socket should get a new proxy.
+	socketCheckInterval = 0
+
+	closeA()
+	closeB := serve("B")
+	defer closeB()
+	check(http.StatusOK, "B")
+	if cached() == first {
+		t.Errorf("proxy wasn't replaced after the socket was replaced")
+	}
+
+	// A removed socket should be evicted.
+	if err := os.Remove(socket); err != nil {
+		t.Fatal(err)
+	}
+	check(http.StatusNotFound, "")
+	if e := cached(); e != nil {
+		t.Errorf("proxy wasn't evicted after the socket was removed")
+	}
+}
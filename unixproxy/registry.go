@@ -0,0 +1,305 @@
+package unixproxy
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// registryPollInterval is how often a Registry rescans its root, if it can't
+// watch it for changes.
+var registryPollInterval = time.Second
+
+// Registry tracks the Unix sockets in a directory tree, and notifies
+// subscribers when sockets appear and disappear. A [Handler] can consult a
+// Registry, rather than the filesystem, for every request.
+//
+// On Linux, the tree is watched via inotify(7), and rescanned whenever
+// anything in it changes. On other platforms, or if inotify isn't available,
+// the tree is rescanned periodically. The same goes for trees with directories
+// that can't be watched, e.g. because the inotify watch limit is exhausted.
+type Registry struct {
+	root    string
+	watcher watcher // nil if polling
+	polling bool    // set if some directories can't be watched
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	mtx     sync.RWMutex
+	sockets map[string]os.FileInfo // by path
+	subs    map[chan Event]struct{}
+}
+
+// EventOp describes a change to a socket.
+type EventOp int
+
+const (
+	// SocketAdded means a socket appeared in the registry.
+	SocketAdded EventOp = iota + 1
+
+	// SocketRemoved means a socket disappeared from the registry.
+	SocketRemoved
+)
+
+// String implements fmt.Stringer.
+func (op EventOp) String() string {
+	switch op {
+	case SocketAdded:
+		return "added"
+	case SocketRemoved:
+		return "removed"
+	default:
+		return fmt.Sprintf("EventOp(%d)", int(op))
+	}
+}
+
+// Event describes a change to a socket in a [Registry]. A socket which is
+// replaced by a new socket at the same path yields a SocketRemoved event,
+// followed by a SocketAdded event.
+type Event struct {
+	Op   EventOp
+	Path string
+}
+
+// NewRegistry returns a registry of the Unix sockets in the directory tree at
+// root, which must be a valid directory. The registry is populated before
+// NewRegistry returns, and kept up to date until it's closed.
+func NewRegistry(root string) (*Registry, error) {
+	if fi, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("invalid root: %s: not a directory", root)
+	}
+
+	w, err := newWatcher()
+	if err != nil {
+		w = nil // the registry polls instead
+	}
+
+	return newRegistry(root, w), nil
+}
+
+// newRegistry returns a registry which is notified of changes by w, or which
+// polls if w is nil.
+func newRegistry(root string, w watcher) *Registry {
+	r := &Registry{
+		root:    root,
+		watcher: w,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		sockets: map[string]os.FileInfo{},
+		subs:    map[chan Event]struct{}{},
+	}
+
+	r.scan()
+
+	go r.loop()
+
+	return r
+}
+
+// Root returns the root directory of the registry.
+func (r *Registry) Root() string {
+	return r.root
+}
+
+// Sockets returns the paths of all sockets in the registry, in lexical order.
+func (r *Registry) Sockets() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	paths := make([]string, 0, len(r.sockets))
+	for path := range r.sockets {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Contains returns true if there's a socket at path in the registry.
+func (r *Registry) Contains(path string) bool {
+	_, ok := r.socket(path)
+	return ok
+}
+
+// socket returns the file info of the socket at path, if it's in the registry.
+func (r *Registry) socket(path string) (os.FileInfo, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	fi, ok := r.sockets[filepath.Clean(path)]
+	return fi, ok
+}
+
+// Subscribe returns a channel which receives an event for every socket that's
+// added to or removed from the registry, and a function which cancels the
+// subscription and closes the channel. The channel is also closed when the
+// registry is closed.
+//
+// Events are dropped if the channel's buffer is full, so subscribers should
+// treat them as hints, and consult Sockets for the current state.
+func (r *Registry) Subscribe() (<-chan Event, func()) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	c := make(chan Event, 64)
+
+	select {
+	case <-r.stop:
+		close(c)
+		return c, func() {}
+	default:
+		r.subs[c] = struct{}{}
+	}
+
+	cancel := func() {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+
+		if _, ok := r.subs[c]; ok {
+			delete(r.subs, c)
+			close(c)
+		}
+	}
+
+	return c, cancel
+}
+
+// Close stops watching the directory tree, and closes all subscriptions.
+func (r *Registry) Close() error {
+	r.once.Do(func() {
+		close(r.stop)
+		<-r.done
+
+		if r.watcher != nil {
+			r.watcher.close()
+		}
+
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+
+		for c := range r.subs {
+			delete(r.subs, c)
+			close(c)
+		}
+	})
+
+	return nil
+}
+
+func (r *Registry) loop() {
+	defer close(r.done)
+
+	var changes <-chan struct{}
+	if r.watcher != nil {
+		changes = r.watcher.changes()
+	}
+
+	ticker := time.NewTicker(registryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-changes:
+			r.scan()
+		case <-ticker.C:
+			if r.watcher == nil || r.polling {
+				r.scan()
+			}
+		}
+	}
+}
+
+// scan walks the directory tree, watching every directory, and updates the
+// registry with the sockets it finds, notifying subscribers of the changes. If
+// any directory can't be watched, the registry falls back to polling, until a
+// later scan manages to watch every directory. Only scan and loop access the
+// polling field, and they never run concurrently.
+func (r *Registry) scan() {
+	var (
+		sockets = map[string]os.FileInfo{}
+		polling = false
+	)
+
+	filepath.WalkDir(r.root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil && path == r.root:
+			return err // root is gone, so are all of its sockets
+		case err != nil:
+			return nil // skip unreadable entries
+		}
+
+		if d.IsDir() && r.watcher != nil {
+			// Before reading entries, so nothing is missed.
+			if err := r.watcher.add(path); err != nil {
+				polling = true
+			}
+		}
+
+		if d.Type()&os.ModeSocket == 0 {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return nil // removed in the meantime
+		}
+
+		sockets[path] = fi
+		return nil
+	})
+
+	r.polling = polling
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var removed, added []string
+	for path, prev := range r.sockets {
+		if fi, ok := sockets[path]; !ok || !sameSocket(prev, fi) {
+			removed = append(removed, path)
+		}
+	}
+	for path, fi := range sockets {
+		if prev, ok := r.sockets[path]; !ok || !sameSocket(prev, fi) {
+			added = append(added, path)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	r.sockets = sockets
+
+	for _, path := range removed {
+		r.broadcastLocked(Event{Op: SocketRemoved, Path: path})
+	}
+	for _, path := range added {
+		r.broadcastLocked(Event{Op: SocketAdded, Path: path})
+	}
+}
+
+// broadcastLocked sends the event to every subscriber, without blocking. The
+// caller must hold the write lock.
+func (r *Registry) broadcastLocked(e Event) {
+	for c := range r.subs {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// watcher signals changes to the directories added to it.
+type watcher interface {
+	add(dir string) error
+	changes() <-chan struct{}
+	close() error
+}
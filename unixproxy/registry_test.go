@@ -0,0 +1,107 @@
+package unixproxy_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/unixtransport"
+	"github.com/peterbourgon/unixtransport/unixproxy"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	foo := listenSocket(t, filepath.Join(root, "foo"))
+
+	registry, err := unixproxy.NewRegistry(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	// Sockets that already exist should be in the registry right away.
+	if want, have := []string{filepath.Join(root, "foo")}, registry.Sockets(); !reflect.DeepEqual(want, have) {
+		t.Errorf("Sockets: want %v, have %v", want, have)
+	}
+
+	events, cancel := registry.Subscribe()
+	defer cancel()
+
+	// Sockets in new subdirectories should be picked up.
+	barPath := filepath.Join(root, "a", "b", "bar")
+	if err := os.MkdirAll(filepath.Dir(barPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	bar := listenSocket(t, barPath)
+	waitEvent(t, events, unixproxy.Event{Op: unixproxy.SocketAdded, Path: barPath})
+
+	if want, have := []string{filepath.Join(root, "a", "b", "bar"), filepath.Join(root, "foo")}, registry.Sockets(); !reflect.DeepEqual(want, have) {
+		t.Errorf("Sockets: want %v, have %v", want, have)
+	}
+
+	if !registry.Contains(barPath) {
+		t.Errorf("Contains(%s): want true, have false", barPath)
+	}
+
+	// Closing the listeners removes the socket files.
+	bar.Close()
+	waitEvent(t, events, unixproxy.Event{Op: unixproxy.SocketRemoved, Path: barPath})
+
+	foo.Close()
+	waitEvent(t, events, unixproxy.Event{Op: unixproxy.SocketRemoved, Path: filepath.Join(root, "foo")})
+
+	if want, have := []string{}, registry.Sockets(); !reflect.DeepEqual(want, have) {
+		t.Errorf("Sockets: want %v, have %v", want, have)
+	}
+
+	// Closing the registry closes the subscription.
+	registry.Close()
+	for range events {
+		t.Errorf("unexpected event after Close")
+	}
+}
+
+func TestRegistryInvalidRoot(t *testing.T) {
+	t.Parallel()
+
+	if _, err := unixproxy.NewRegistry(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("want error, have none")
+	}
+}
+
+func listenSocket(t *testing.T, path string) net.Listener {
+	t.Helper()
+
+	ln, err := unixtransport.ListenURI(context.Background(), "unix://"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	return ln
+}
+
+func waitEvent(t *testing.T, events <-chan unixproxy.Event, want unixproxy.Event) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case have, ok := <-events:
+			if !ok {
+				t.Fatalf("waiting for %v: subscription closed", want)
+			}
+			if have == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("waiting for %v: timeout", want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package unixproxy_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/unixtransport/unixproxy"
+)
+
+func TestHandlerUpgrade(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 100 * time.Millisecond
+
+	root := t.TempDir()
+
+	// The backend switches to a line-based protocol, which echoes each line in
+	// upper case. Once the client is done sending, the backend says goodbye,
+	// and closes its side, too.
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("upgrade") != "shout" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: shout\r\n\r\n")
+		brw.Flush()
+
+		for {
+			line, err := brw.ReadString('\n')
+			if err != nil {
+				break // io.EOF when the client half-closes
+			}
+			brw.WriteString(strings.ToUpper(line))
+			brw.Flush()
+		}
+
+		brw.WriteString("bye\n")
+		brw.Flush()
+		conn.(*net.UnixConn).CloseWrite()
+	}))
+	backend.Listener = listenSocket(t, filepath.Join(root, "foo"))
+	backend.Config.ReadTimeout = timeout
+	backend.Config.WriteTimeout = timeout
+	backend.Start()
+	defer backend.Close()
+
+	// Server timeouts shouldn't apply to upgraded connections.
+	proxy := httptest.NewUnstartedServer(&unixproxy.Handler{Host: "unixproxy.localhost", Root: root})
+	proxy.Config.ReadTimeout = timeout
+	proxy.Config.WriteTimeout = timeout
+	proxy.Config.IdleTimeout = timeout
+	proxy.Start()
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	req, _ := http.NewRequest("GET", "http://foo.unixproxy.localhost/", nil)
+	req.Header.Set("connection", "Upgrade")
+	req.Header.Set("upgrade", "shout")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := http.StatusSwitchingProtocols, resp.StatusCode; want != have {
+		t.Fatalf("status: want %d, have %d", want, have)
+	}
+
+	if want, have := "shout", resp.Header.Get("upgrade"); want != have {
+		t.Fatalf("upgrade: want %q, have %q", want, have)
+	}
+
+	exchange := func(send, want string) {
+		t.Helper()
+
+		if _, err := io.WriteString(conn, send+"\n"); err != nil {
+			t.Fatal(err)
+		}
+
+		have, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := want+"\n", have; want != have {
+			t.Errorf("send %q: want %q, have %q", send, want, have)
+		}
+	}
+
+	exchange("hello", "HELLO")
+
+	// Stay idle for longer than all of the timeouts.
+	time.Sleep(5 * timeout)
+
+	exchange("still there?", "STILL THERE?")
+
+	// Half-close the connection: the backend should see EOF, and still be able
+	// to respond, before closing its side.
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	rest, err := io.ReadAll(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := "bye\n", string(rest); want != have {
+		t.Errorf("after half-close: want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,66 @@
+package unixproxy
+
+import (
+	"os"
+	"syscall"
+)
+
+// inotifyWatcher is a watcher via inotify(7). It doesn't parse the events, it
+// only signals that something changed, and leaves it to the registry to
+// rescan.
+type inotifyWatcher struct {
+	fd int
+	f  *os.File
+	c  chan struct{}
+}
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+func newWatcher() (watcher, error) {
+	// A non-blocking fd is managed by the runtime poller, so that closing the
+	// file interrupts a pending read.
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &inotifyWatcher{
+		fd: fd,
+		f:  os.NewFile(uintptr(fd), "inotify"),
+		c:  make(chan struct{}, 1),
+	}
+
+	go w.read()
+
+	return w, nil
+}
+
+func (w *inotifyWatcher) add(dir string) error {
+	// Adding a directory that's already watched is a no-op.
+	_, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	return os.NewSyscallError("inotify_add_watch", err)
+}
+
+func (w *inotifyWatcher) changes() <-chan struct{} {
+	return w.c
+}
+
+func (w *inotifyWatcher) close() error {
+	return w.f.Close()
+}
+
+func (w *inotifyWatcher) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		if _, err := w.f.Read(buf); err != nil {
+			return
+		}
+
+		// Coalesce changes that happen while the registry is still scanning.
+		select {
+		case w.c <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package unixproxy
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// newWatcher isn't supported on this platform, so registries poll instead.
+func newWatcher() (watcher, error) {
+	return nil, fmt.Errorf("watching directories isn't supported on %s", runtime.GOOS)
+}
@@ -2,11 +2,15 @@ package unixtransport
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
 	"strings"
+	"syscall"
 )
 
 // ParseURI parses the given `uri` into a network and address, suitable for use
@@ -20,10 +24,30 @@ import (
 // If the URI doesn't have a scheme, "tcp://" is assumed by default, in an
 // attempt to keep basic compatibility with common listen addresses like
 // "localhost:8080" or ":9090".
+//
+// Unix sockets in the abstract namespace are identified by a leading '@', e.g.
+// "unix://@my-socket" yields network "unix" and address "@my-socket". Go maps
+// such addresses to the abstract namespace on Linux.
+//
+// Networks "fd" and "systemd" identify inherited listeners, see
+// [ListenURIConfig]. The URI "fd://3" yields network "fd" and address "3", the
+// URI "systemd://name" yields network "systemd" and address "name", and the URI
+// "systemd://" yields network "systemd" and an empty address.
+//
+// Networks with a "tls+" prefix, like "tls+tcp" and "tls+unix", identify
+// listeners wrapped with TLS, see [ListenURIConfig]. The prefix is returned as
+// part of the network.
 func ParseURI(uri string) (network, address string, _ error) {
+	network, address, _, err := parseURI(uri)
+	return network, address, err
+}
+
+// parseURI implements ParseURI, and also returns the URI query, which carries
+// options for e.g. ListenURIConfig.
+func parseURI(uri string) (network, address string, query url.Values, _ error) {
 	uri = strings.TrimSpace(uri)
 	if uri == "" {
-		return "", "", fmt.Errorf("empty URI")
+		return "", "", nil, fmt.Errorf("empty URI")
 	}
 
 	if !strings.Contains(uri, "://") {
@@ -32,18 +56,22 @@ func ParseURI(uri string) (network, address string, _ error) {
 
 	u, err := url.Parse(uri)
 	if err != nil {
-		return "", "", fmt.Errorf("parse URI: %w", err)
+		return "", "", nil, fmt.Errorf("parse URI: %w", err)
 	}
 
 	if u.Host == "" && u.Path != "" {
 		u.Host = u.Path
 	}
 
-	if u.Host == "" {
-		return "", "", fmt.Errorf("empty host in URI (%s)", uri)
+	if network, _ := splitTLSNetwork(u.Scheme); isUnixNetwork(network) && isAbstractURL(u) && u.Host != "" {
+		u.Host = "@" + u.Host
 	}
 
-	return u.Scheme, u.Host, nil
+	if u.Host == "" && u.Scheme != "systemd" {
+		return "", "", nil, fmt.Errorf("empty host in URI (%s)", uri)
+	}
+
+	return u.Scheme, u.Host, u.Query(), nil
 }
 
 // ListenURI is a convenience function that calls [ListenURIConfig] with a
@@ -56,29 +84,234 @@ func ListenURI(ctx context.Context, uri string) (net.Listener, error) {
 // then constructs a listener on that network and address, using the provided
 // [net.ListenConfig].
 //
-// If the network is "unix" or "unixpacket", it first removes any existing
-// socket file at the address, ignoring [os.IsNotExist] errors.
+// If the network is "unix" or "unixpacket", it first removes any stale socket
+// file at the address. A file which isn't a socket is never removed, and yields
+// an error. A socket is only considered stale if dialing it is refused; if the
+// dial succeeds, another listener is still serving the socket, and a
+// [*SocketInUseError] is returned. Setting the URI query parameter
+// "remove=force" restores the old behavior, which unconditionally removes any
+// file at the address. Addresses in the abstract namespace have no socket file,
+// and are left alone.
+//
+// Unix sockets on the filesystem can also be configured with the URI query
+// parameters "mode", an octal file mode; "owner", a user name or numeric user
+// ID; and "group", a group name or numeric group ID. For example,
+// "unix:///run/app.sock?mode=0660&group=www-data" creates a socket that can be
+// used by members of the www-data group. To make this atomic, the socket is
+// created in a private temporary directory next to the address, and only moved
+// to the address once these have been applied, so clients never see the socket
+// with the permissions from the umask. If applying them fails, the socket is
+// closed and removed, and an error is returned.
+//
+// Listeners on Unix sockets on the filesystem remove their socket file when
+// they're closed, but only if the file is still the socket they created, and
+// not e.g. a socket created by a newer listener at the same path. Setting the
+// URI query parameter "unlink=false" leaves the socket file in place.
+//
+// Setting the URI query parameter "lock=true" guards the socket with an
+// exclusive flock(2) on the file <socket>.lock, which is acquired before any
+// stale socket is removed, and held until the listener is closed. If another
+// process holds the lock, a [*SocketInUseError] with its process ID is
+// returned. This prevents processes that race to listen on the same socket
+// from removing each other's socket files.
+//
+// If the network has a "tls+" prefix, like "tls+tcp" or "tls+unix", the
+// listener is wrapped with TLS, using the certificate and private key from the
+// PEM files given by the URI query parameters "cert" and "key". If the query
+// parameter "client-ca" is also given, clients must present a certificate
+// signed by one of the CAs in that PEM file. For example,
+// "tls+unix:///run/app.sock?cert=/etc/app/cert.pem&key=/etc/app/key.pem" serves
+// TLS on a Unix socket, which clients can reach with "https+unix" URLs, see
+// [Register]. Inherited listeners can be wrapped too, e.g. "tls+systemd://".
+// The certificate and key files are checked for changes at the start of each
+// handshake, and reloaded if they've changed, so certificates can be rotated
+// without restarting. If the reload fails, e.g. because only one of the files
+// has been replaced so far, the previous certificate is served until it
+// succeeds.
+//
+// Other query parameters are ignored. Use [NewURI] to reject them up front.
+//
+// If the network is "fd", the address must be the number of an inherited file
+// descriptor, which is adopted as the listener. If the network is "systemd",
+// the listener is adopted from the sockets passed by systemd socket activation
+// via LISTEN_FDS, see sd_listen_fds(3). An empty address selects the first
+// passed socket; otherwise, the address selects a socket by its name in
+// LISTEN_FDNAMES, which is set by FileDescriptorName= in the .socket unit, and
+// defaults to the unit name, e.g. "myapp.socket". In both cases, the inherited
+// file descriptor is closed, so each socket can only be adopted once, and the
+// provided [net.ListenConfig] isn't used.
 //
 // The provided `ctx` is only used when resolving the listen address, it has no
 // effect on the returned listener.
 //
-// For more precise control, use [ParseURI] and constructa listener yourself.
+// Packet networks like "udp" and "unixgram" aren't supported, see
+// [ListenPacketURIConfig] instead.
+//
+// For more precise control, use [ParseURI] and construct a listener yourself.
 func ListenURIConfig(ctx context.Context, uri string, config net.ListenConfig) (net.Listener, error) {
-	network, address, err := ParseURI(uri)
+	network, address, query, err := parseURI(uri)
 	if err != nil {
 		return nil, err
 	}
 
-	if network == "unix" || network == "unixpacket" {
-		if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
-			return nil, fmt.Errorf("remove stale socket (%s): %w", address, err)
+	options, err := parseListenOptions(network, address, query)
+	if err != nil {
+		return nil, err
+	}
+
+	network, _ = splitTLSNetwork(network)
+
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case network == "fd" || network == "systemd":
+		f, err := inheritedFile(network, address)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		listener, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
 		}
+
+		return wrapTLS(listener, tlsConfig), nil
+
+	case isPacketNetwork(network):
+		return nil, fmt.Errorf("%s is a packet network, use ListenPacketURI", network)
 	}
 
-	listener, err := config.Listen(ctx, network, address)
+	lock, err := prepareSocket(ctx, network, address, options)
 	if err != nil {
-		return nil, fmt.Errorf("listen: %w", err)
+		return nil, err
 	}
 
-	return listener, nil
+	var listener net.Listener
+	if err := createSocket(address, options, func(path string) (io.Closer, error) {
+		var err error
+		if listener, err = config.Listen(ctx, network, path); err != nil {
+			return nil, fmt.Errorf("listen: %w", err)
+		}
+		return listener, nil
+	}); err != nil {
+		lock.release()
+		return nil, err
+	}
+
+	if ul, ok := listener.(*net.UnixListener); ok && !isAbstractAddress(address) {
+		sl, err := newSocketListener(ul, address, lock)
+		if err != nil {
+			ul.Close()
+			lock.release()
+			return nil, err
+		}
+
+		sl.file.keep = options.keepSocket
+		listener = sl
+	}
+
+	return wrapTLS(listener, tlsConfig), nil
+}
+
+// wrapTLS wraps listener with TLS, if config is non-nil.
+func wrapTLS(listener net.Listener, config *tls.Config) net.Listener {
+	if config == nil {
+		return listener
+	}
+	return tls.NewListener(listener, config)
+}
+
+// prepareSocket takes the socket lock, if configured, and removes any stale
+// socket file, for Unix networks. It returns the lock, which may be nil.
+func prepareSocket(ctx context.Context, network, address string, options listenOptions) (*socketLock, error) {
+	var lock *socketLock
+	if options.lock {
+		var err error
+		if lock, err = lockSocket(address); err != nil {
+			return nil, err
+		}
+	}
+
+	if isUnixNetwork(network) && !isAbstractAddress(address) {
+		if err := removeStaleSocket(ctx, network, address, options.forceRemove); err != nil {
+			lock.release()
+			return nil, err
+		}
+	}
+
+	return lock, nil
+}
+
+// SocketInUseError is returned when listening on a Unix socket which is still
+// being served by another listener, or which is locked by another process.
+type SocketInUseError struct {
+	Address string
+	PID     int // of the process holding the socket lock, if known
+}
+
+// Error implements the error interface.
+func (e *SocketInUseError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("socket %s is in use by pid %d", e.Address, e.PID)
+	}
+	return fmt.Sprintf("socket %s is in use by another listener", e.Address)
+}
+
+// removeStaleSocket removes the socket file at address, if it exists and isn't
+// being served by another listener. If force is true, it removes any file at
+// address without checking.
+func removeStaleSocket(ctx context.Context, network, address string, force bool) error {
+	if force {
+		if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove stale socket (%s): %w", address, err)
+		}
+		return nil
+	}
+
+	fi, err := os.Lstat(address)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("check stale socket (%s): %w", address, err)
+	case fi.Mode()&os.ModeSocket == 0:
+		return fmt.Errorf("check stale socket (%s): file exists and isn't a socket", address)
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+	switch {
+	case err == nil:
+		conn.Close()
+		return &SocketInUseError{Address: address}
+	case !errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("check stale socket (%s): %w", address, err)
+	}
+
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale socket (%s): %w", address, err)
+	}
+
+	return nil
+}
+
+func isPacketNetwork(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram", "ip", "ip4", "ip6":
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnixNetwork(network string) bool {
+	switch network {
+	case "unix", "unixgram", "unixpacket":
+		return true
+	default:
+		return false
+	}
 }
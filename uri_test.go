@@ -2,13 +2,16 @@ package unixtransport_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"syscall"
 	"testing"
 
@@ -59,6 +62,24 @@ func TestParseURI(t *testing.T) {
 		{uri: "tcp://", err: true},
 		{uri: "[::]:8080", network: "tcp", address: "[::]:8080"},
 		{uri: "tcp://[::]:8080", network: "tcp", address: "[::]:8080"},
+
+		// Abstract Unix sockets.
+		{uri: "unix://@my-socket", network: "unix", address: "@my-socket"},
+		{uri: "unixpacket://@my-socket", network: "unixpacket", address: "@my-socket"},
+		{uri: "unix://@/tmp/my.sock", network: "unix", address: "@/tmp/my.sock"},
+		{uri: "unix://@my-socket?a=b", network: "unix", address: "@my-socket"},
+		{uri: "unix://@", err: true},
+
+		// Inherited listeners.
+		{uri: "fd://3", network: "fd", address: "3"},
+		{uri: "systemd://myapp.socket", network: "systemd", address: "myapp.socket"},
+		{uri: "systemd://", network: "systemd", address: ""},
+		{uri: "fd://", err: true},
+
+		// TLS listeners.
+		{uri: "tls+tcp://:443", network: "tls+tcp", address: ":443"},
+		{uri: "tls+unix:///tmp/my.sock?cert=c.pem&key=k.pem", network: "tls+unix", address: "/tmp/my.sock"},
+		{uri: "tls+unix://@my-socket", network: "tls+unix", address: "@my-socket"},
 	} {
 		t.Run(testcase.uri, func(t *testing.T) {
 			network, address, err := unixtransport.ParseURI(testcase.uri)
@@ -156,6 +177,197 @@ func TestListenURIConfig(t *testing.T) {
 	}
 }
 
+func TestListenURIAbstract(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skipf("abstract Unix sockets aren't supported on %s", runtime.GOOS)
+	}
+
+	ctx := context.Background()
+	name := abstractName(t)
+
+	ln, err := unixtransport.ListenURI(ctx, "unix://@"+name)
+	if err != nil {
+		t.Fatalf("ListenURI failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	if want, have := "@"+name, ln.Addr().String(); want != have {
+		t.Errorf("Addr: want %q, have %q", want, have)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "hello", r.URL.Path, r.Header.Get("authorization"))
+	})
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = ln
+	server.Start()
+	t.Cleanup(func() { server.Close() })
+
+	transport := &http.Transport{}
+	unixtransport.Register(transport)
+	client := &http.Client{Transport: transport}
+
+	for rawurl, want := range map[string]string{
+		"http+unix://@" + name + ":/foo": "hello /foo",
+		"http+unix://@" + name + "/bar":  "hello /bar",
+		"http+unix://@" + name:           "hello /",
+	} {
+		if have := get(t, client, rawurl); want != have {
+			t.Errorf("%s: want %q, have %q", rawurl, want, have)
+		}
+	}
+
+	// Listening again on the same abstract address should fail, rather than
+	// trying to remove a socket file that doesn't exist.
+	if _, err := unixtransport.ListenURI(ctx, "unix://@"+name); err == nil {
+		t.Errorf("second ListenURI: want error, have none")
+	}
+}
+
+func TestListenURIAbstractPath(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skipf("abstract Unix sockets aren't supported on %s", runtime.GOOS)
+	}
+
+	// Abstract names that look like paths must address the same socket on the
+	// listen side and on the client side, rather than the filesystem path.
+	ctx := context.Background()
+	name := "/tmp/" + abstractName(t) + ".sock"
+
+	ln, err := unixtransport.ListenURI(ctx, "unix://@"+name)
+	if err != nil {
+		t.Fatalf("ListenURI failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	if want, have := "@"+name, ln.Addr().String(); want != have {
+		t.Errorf("Addr: want %q, have %q", want, have)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Stat(%s): want not-exist error, have %v", name, err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "hello", r.URL.Path) })
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = ln
+	server.Start()
+	t.Cleanup(func() { server.Close() })
+
+	transport := &http.Transport{}
+	unixtransport.Register(transport)
+	client := &http.Client{Transport: transport}
+
+	rawurl := "http+unix://@" + name + ":/foo"
+	if want, have := "hello /foo", get(t, client, rawurl); want != have {
+		t.Errorf("%s: want %q, have %q", rawurl, want, have)
+	}
+
+	conn, err := unixtransport.DialURI(ctx, rawurl)
+	if err != nil {
+		t.Fatalf("DialURI(%s): %v", rawurl, err)
+	}
+	defer conn.Close()
+
+	if want, have := "@"+name, conn.RemoteAddr().String(); want != have {
+		t.Errorf("DialURI(%s): remote addr: want %q, have %q", rawurl, want, have)
+	}
+}
+
+func abstractName(t *testing.T) string {
+	t.Helper()
+	return fmt.Sprintf("unixtransport-test-%d-%d", os.Getpid(), rand.Int63())
+}
+
+func TestListenURIPermissions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("mode and group", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sock")
+		uri := fmt.Sprintf("unix://%s?mode=0604&group=%d", socket, os.Getgid())
+
+		ln, err := unixtransport.ListenURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("ListenURI(%s): %v", uri, err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		fi, err := os.Stat(socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := os.FileMode(0o604), fi.Mode().Perm(); want != have {
+			t.Errorf("mode: want %v, have %v", want, have)
+		}
+
+		// The socket is created elsewhere and moved into place, which should
+		// leave no trace.
+		if want, have := socket, ln.Addr().String(); want != have {
+			t.Errorf("Addr: want %q, have %q", want, have)
+		}
+		entries, err := os.ReadDir(filepath.Dir(socket))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := 1, len(entries); want != have {
+			t.Errorf("directory entries: want %d, have %d", want, have)
+		}
+
+		// The socket should still be usable, and removed on close.
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		conn.Close()
+		ln.Close()
+		if _, err := os.Stat(socket); !os.IsNotExist(err) {
+			t.Errorf("after Close: want not-exist error, have %v", err)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, uri := range []string{
+			"unix://" + dir + "/1?mode=999",
+			"unix://" + dir + "/2?mode=01777",
+			"unix://" + dir + "/3?owner=no-such-user-hopefully",
+			"unix://" + dir + "/4?group=no-such-group-hopefully",
+			"unix://" + dir + "/5?remove=maybe",
+			"unix://" + dir + "/6?unlink=maybe",
+			"unix://@abstract?mode=0600",
+			"tcp://localhost:0?mode=0600",
+		} {
+			if ln, err := unixtransport.ListenURI(ctx, uri); err == nil {
+				ln.Close()
+				t.Errorf("ListenURI(%s): want error, have none", uri)
+			}
+		}
+	})
+
+	t.Run("chown fails", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can chown to any owner")
+		}
+
+		socket := filepath.Join(t.TempDir(), "sock")
+		uri := "unix://" + socket + "?owner=0"
+		if ln, err := unixtransport.ListenURI(ctx, uri); err == nil {
+			ln.Close()
+			t.Fatalf("ListenURI(%s): want error, have none", uri)
+		}
+
+		if _, err := os.Stat(socket); !os.IsNotExist(err) {
+			t.Errorf("socket file: want not-exist error, have %v", err)
+		}
+	})
+}
+
 func TestListenURIRemoveFailures(t *testing.T) {
 	t.Parallel()
 
@@ -169,6 +381,10 @@ func TestListenURIRemoveFailures(t *testing.T) {
 	})
 
 	t.Run("bad permission", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can remove files from read-only directories")
+		}
+
 		dir := filepath.Join(t.TempDir(), "dir")
 		if err := os.Mkdir(dir, 0o755); err != nil {
 			t.Fatalf("os.Mkdir: %v", err)
@@ -181,7 +397,8 @@ func TestListenURIRemoveFailures(t *testing.T) {
 
 		// The only way to trigger an error on the os.Remove of the socket file
 		// in a unit test like this one, is to remove the write permission on
-		// the parent directory.
+		// the parent directory. The file isn't a socket, so we need to force
+		// the removal.
 		if err := os.Chmod(dir, 0o555); err != nil {
 			t.Fatalf("os.Chmod(%s, 0555): %v", dir, err)
 		}
@@ -191,7 +408,7 @@ func TestListenURIRemoveFailures(t *testing.T) {
 			}
 		}()
 
-		uri := "unix://" + sock
+		uri := "unix://" + sock + "?remove=force"
 		if _, err := unixtransport.ListenURI(ctx, uri); err == nil {
 			t.Fatalf("ListenURI(%s): expected error, got none", uri)
 		}
@@ -219,6 +436,200 @@ func TestListenURIRemoveFailures(t *testing.T) {
 	})
 }
 
+func TestListenURIStaleSocket(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("regular file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, []byte("important"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		uri := "unix://" + file
+		if ln, err := unixtransport.ListenURI(ctx, uri); err == nil {
+			ln.Close()
+			t.Fatalf("ListenURI(%s): want error, have none", uri)
+		}
+
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("file should still exist, but: %v", err)
+		}
+
+		uri = "unix://" + file + "?remove=force"
+		ln, err := unixtransport.ListenURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("ListenURI(%s): %v", uri, err)
+		}
+		ln.Close()
+	})
+
+	t.Run("stale socket", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sock")
+
+		// Leave a socket file behind, without a listener.
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln.(*net.UnixListener).SetUnlinkOnClose(false)
+		ln.Close()
+
+		uri := "unix://" + socket
+		ln, err = unixtransport.ListenURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("ListenURI(%s): %v", uri, err)
+		}
+		ln.Close()
+	})
+
+	t.Run("live socket", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sock")
+
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		uri := "unix://" + socket
+		_, err = unixtransport.ListenURI(ctx, uri)
+		var inUse *unixtransport.SocketInUseError
+		if !errors.As(err, &inUse) {
+			t.Fatalf("ListenURI(%s): want SocketInUseError, have %v", uri, err)
+		}
+		if want, have := socket, inUse.Address; want != have {
+			t.Errorf("SocketInUseError address: want %q, have %q", want, have)
+		}
+
+		// The original listener should be unaffected.
+		go func() {
+			if conn, err := ln.Accept(); err == nil {
+				conn.Close()
+			}
+		}()
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			t.Fatalf("dial original listener: %v", err)
+		}
+		conn.Close()
+	})
+}
+
+func TestListenURIUnlink(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("default", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sock")
+
+		ln, err := unixtransport.ListenURI(ctx, "unix://"+socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server := httptest.NewUnstartedServer(http.NotFoundHandler())
+		server.Listener = ln
+		server.Start()
+		server.Close()
+
+		if _, err := os.Stat(socket); !os.IsNotExist(err) {
+			t.Errorf("socket file after shutdown: want not-exist error, have %v", err)
+		}
+	})
+
+	t.Run("unlink=false", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sock")
+
+		ln, err := unixtransport.ListenURI(ctx, "unix://"+socket+"?unlink=false")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln.Close()
+
+		if _, err := os.Stat(socket); err != nil {
+			t.Errorf("socket file after Close: %v", err)
+		}
+	})
+
+	t.Run("replaced", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sock")
+
+		ln, err := unixtransport.ListenURI(ctx, "unix://"+socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Something else replaces the socket file while the listener is open.
+		if err := os.Remove(socket); err != nil {
+			t.Fatal(err)
+		}
+		other, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { other.Close() })
+
+		ln.Close()
+
+		if _, err := os.Stat(socket); err != nil {
+			t.Errorf("replaced socket file after Close: %v", err)
+		}
+	})
+}
+
+func TestListenURILock(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		socket = filepath.Join(t.TempDir(), "sock")
+		uri    = "unix://" + socket + "?lock=true"
+	)
+
+	ln, err := unixtransport.ListenURI(ctx, uri)
+	if err != nil {
+		t.Fatalf("ListenURI(%s): %v", uri, err)
+	}
+
+	buf, err := os.ReadFile(socket + ".lock")
+	if err != nil {
+		t.Fatalf("read lock file: %v", err)
+	}
+	if want, have := fmt.Sprintf("%d\n", os.Getpid()), string(buf); want != have {
+		t.Errorf("lock file: want %q, have %q", want, have)
+	}
+
+	// The lock is taken before the socket is probed, so even a forced removal
+	// should fail, and the error should identify the lock owner.
+	_, err = unixtransport.ListenURI(ctx, uri+"&remove=force")
+	var inUse *unixtransport.SocketInUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("second ListenURI(%s): want SocketInUseError, have %v", uri, err)
+	}
+	if want, have := os.Getpid(), inUse.PID; want != have {
+		t.Errorf("SocketInUseError PID: want %d, have %d", want, have)
+	}
+	if _, err := os.Stat(socket); err != nil {
+		t.Errorf("socket file after second ListenURI: %v", err)
+	}
+
+	// Closing the listener releases the lock, and removes the lock file.
+	ln.Close()
+	ln.Close()
+	if _, err := os.Stat(socket + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file after Close: want not-exist error, have %v", err)
+	}
+
+	ln, err = unixtransport.ListenURI(ctx, uri)
+	if err != nil {
+		t.Fatalf("ListenURI(%s) after Close: %v", uri, err)
+	}
+	ln.Close()
+}
+
 func TestListenURI_IPv6(t *testing.T) {
 	t.Parallel()
 
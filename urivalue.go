@@ -0,0 +1,148 @@
+package unixtransport
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// URI is a validated listen URI, as accepted by [ListenURIConfig]. Unlike
+// [ParseURI], which accepts any scheme as a network, constructing a URI via
+// [NewURI] rejects networks that can't be used to listen for stream
+// connections, malformed addresses, and unknown or invalid options.
+//
+// URI implements [flag.Value], [encoding.TextMarshaler], and
+// [encoding.TextUnmarshaler], so it can be used directly in flag sets and
+// config files.
+//
+//	var addr unixtransport.URI
+//	fs.Var(&addr, "addr", "listen address")
+//
+// The zero value is empty, and not valid for listening.
+type URI struct {
+	// Network is e.g. "tcp", "unix", or "tls+unix".
+	Network string
+
+	// Address is e.g. ":8080" or "/tmp/my.sock".
+	Address string
+
+	// Options are the URI query parameters, e.g. "mode" for Unix sockets. See
+	// ListenURIConfig for details. Nil if there are no options.
+	Options url.Values
+}
+
+// NewURI parses and validates the given `uri`. Like [ParseURI], "tcp://" is
+// assumed if the URI doesn't have a scheme.
+func NewURI(uri string) (URI, error) {
+	network, address, query, err := parseURI(uri)
+	if err != nil {
+		return URI{}, err
+	}
+
+	if len(query) == 0 {
+		query = nil
+	}
+
+	u := URI{
+		Network: network,
+		Address: address,
+		Options: query,
+	}
+
+	if err := u.validate(); err != nil {
+		return URI{}, fmt.Errorf("invalid URI %s: %w", uri, err)
+	}
+
+	return u, nil
+}
+
+func (u URI) validate() error {
+	switch network, _ := splitTLSNetwork(u.Network); network {
+	case "tcp", "tcp4", "tcp6":
+		if _, _, err := net.SplitHostPort(u.Address); err != nil {
+			return err
+		}
+
+	case "unix", "unixpacket":
+		if u.Address == "" || u.Address == "@" {
+			return fmt.Errorf("empty socket path")
+		}
+
+	case "fd":
+		if fd, err := strconv.Atoi(u.Address); err != nil || fd < 0 {
+			return fmt.Errorf("invalid file descriptor %q", u.Address)
+		}
+
+	case "systemd":
+		// Any name is valid, including the empty name.
+
+	default:
+		return fmt.Errorf("unsupported network %q", u.Network)
+	}
+
+	var unknown []string
+	for key := range u.Options {
+		if !listenOptionKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown options: %s", strings.Join(unknown, ", "))
+	}
+
+	if _, err := parseListenOptions(u.Network, u.Address, u.Options); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// String returns the URI in a form that [NewURI] parses back to an equal URI.
+// The zero value returns the empty string.
+func (u URI) String() string {
+	if u.Network == "" && u.Address == "" {
+		return ""
+	}
+
+	// Escape the address like the path or host of a URL, so that e.g. Unix
+	// socket paths with special characters and IPv6 zones survive a round trip.
+	var s string
+	switch network, _ := splitTLSNetwork(u.Network); {
+	case isUnixNetwork(network) && !isAbstractAddress(u.Address):
+		s = u.Network + "://" + (&url.URL{Path: u.Address}).EscapedPath()
+	case isUnixNetwork(network) || u.Address == "":
+		s = u.Network + "://" + u.Address
+	default:
+		s = (&url.URL{Scheme: u.Network, Host: u.Address}).String()
+	}
+	if len(u.Options) > 0 {
+		s += "?" + u.Options.Encode()
+	}
+
+	return s
+}
+
+// Set implements [flag.Value].
+func (u *URI) Set(s string) error {
+	parsed, err := NewURI(s)
+	if err != nil {
+		return err
+	}
+
+	*u = parsed
+	return nil
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (u URI) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (u *URI) UnmarshalText(text []byte) error {
+	return u.Set(string(text))
+}
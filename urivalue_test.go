@@ -0,0 +1,152 @@
+package unixtransport_test
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestNewURI(t *testing.T) {
+	for _, testcase := range []struct {
+		uri  string
+		want unixtransport.URI
+		err  bool
+	}{
+		{uri: ":8080", want: unixtransport.URI{Network: "tcp", Address: ":8080"}},
+		{uri: "tcp4://localhost:0", want: unixtransport.URI{Network: "tcp4", Address: "localhost:0"}},
+		{uri: "tcp://[::1]:8080", want: unixtransport.URI{Network: "tcp", Address: "[::1]:8080"}},
+		{uri: "unix:///tmp/my.sock", want: unixtransport.URI{Network: "unix", Address: "/tmp/my.sock"}},
+		{uri: "unixpacket://@my-socket", want: unixtransport.URI{Network: "unixpacket", Address: "@my-socket"}},
+		{uri: "unix:///run/app.sock?mode=0660&lock=true", want: unixtransport.URI{Network: "unix", Address: "/run/app.sock", Options: url.Values{"mode": {"0660"}, "lock": {"true"}}}},
+		{uri: "fd://3", want: unixtransport.URI{Network: "fd", Address: "3"}},
+		{uri: "systemd://", want: unixtransport.URI{Network: "systemd"}},
+		{uri: "systemd://myapp.socket", want: unixtransport.URI{Network: "systemd", Address: "myapp.socket"}},
+		{uri: "tls+tcp://:443?cert=c.pem&key=k.pem", want: unixtransport.URI{Network: "tls+tcp", Address: ":443", Options: url.Values{"cert": {"c.pem"}, "key": {"k.pem"}}}},
+		{uri: "tls+unix://@my-socket?cert=c.pem&key=k.pem", want: unixtransport.URI{Network: "tls+unix", Address: "@my-socket", Options: url.Values{"cert": {"c.pem"}, "key": {"k.pem"}}}},
+
+		{uri: "", err: true},
+		{uri: "http://example.com:8080", err: true},
+		{uri: "file:///path/to/file.txt", err: true},
+		{uri: "udp://:12345", err: true},
+		{uri: "unixgram:///tmp/my.sock", err: true},
+		{uri: "localhost", err: true},
+		{uri: "tcp://localhost", err: true},
+		{uri: "fd://x", err: true},
+		{uri: "fd://-1", err: true},
+		{uri: "unix:///tmp/my.sock?mode=abc", err: true},
+		{uri: "unix:///tmp/my.sock?nope=1", err: true},
+		{uri: "tcp://:8080?mode=0660", err: true},
+		{uri: "tcp://:8080?cert=c.pem&key=k.pem", err: true},
+		{uri: "tls+tcp://:443?cert=c.pem", err: true},
+		{uri: "tls+udp://:443?cert=c.pem&key=k.pem", err: true},
+	} {
+		t.Run(testcase.uri, func(t *testing.T) {
+			u, err := unixtransport.NewURI(testcase.uri)
+			switch {
+			case testcase.err && err == nil:
+				t.Fatalf("want error, have %+v", u)
+			case !testcase.err && err != nil:
+				t.Fatal(err)
+			}
+			if have, want := u, testcase.want; !reflect.DeepEqual(want, have) {
+				t.Errorf("want %+v, have %+v", want, have)
+			}
+		})
+	}
+}
+
+func TestURIString(t *testing.T) {
+	for _, testcase := range []struct {
+		uri  string
+		want string
+	}{
+		{uri: ":8080", want: "tcp://:8080"},
+		{uri: "tcp6://[::1]:0", want: "tcp6://[::1]:0"},
+		{uri: "tcp://[fe80::1%25eth0]:80", want: "tcp://[fe80::1%25eth0]:80"},
+		{uri: "unix:///tmp/my.sock", want: "unix:///tmp/my.sock"},
+		{uri: "unix:///tmp/my%20dir/app:v2.sock", want: "unix:///tmp/my%20dir/app:v2.sock"},
+		{uri: "unix:///tmp/what%3F.sock", want: "unix:///tmp/what%3F.sock"},
+		{uri: "unix://@my-socket", want: "unix://@my-socket"},
+		{uri: "unix://@/tmp/my.sock", want: "unix://@/tmp/my.sock"},
+		{uri: "unix:///run/app.sock?mode=0660&group=0", want: "unix:///run/app.sock?group=0&mode=0660"},
+		{uri: "fd://3", want: "fd://3"},
+		{uri: "systemd://", want: "systemd://"},
+		{uri: "tls+unix:///tmp/my.sock?key=k.pem&cert=c.pem", want: "tls+unix:///tmp/my.sock?cert=c.pem&key=k.pem"},
+	} {
+		t.Run(testcase.uri, func(t *testing.T) {
+			u, err := unixtransport.NewURI(testcase.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want, have := testcase.want, u.String(); want != have {
+				t.Errorf("String: want %q, have %q", want, have)
+			}
+
+			u2, err := unixtransport.NewURI(u.String())
+			if err != nil {
+				t.Fatalf("round trip: %v", err)
+			}
+
+			if !reflect.DeepEqual(u, u2) {
+				t.Errorf("round trip: want %+v, have %+v", u, u2)
+			}
+		})
+	}
+
+	if want, have := "", (unixtransport.URI{}).String(); want != have {
+		t.Errorf("zero value: want %q, have %q", want, have)
+	}
+}
+
+func TestURIFlag(t *testing.T) {
+	var u unixtransport.URI
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&u, "addr", "listen address")
+
+	if err := fs.Parse([]string{"-addr", "unix:///tmp/my.sock?mode=0600"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := "unix:///tmp/my.sock?mode=0600", u.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	if err := fs.Parse([]string{"-addr", "http://example.com"}); err == nil {
+		t.Errorf("want error, have none")
+	}
+}
+
+func TestURIText(t *testing.T) {
+	var config struct {
+		Addr unixtransport.URI `json:"addr"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"addr":"unix://@my-socket"}`), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := (unixtransport.URI{Network: "unix", Address: "@my-socket"}), config.Addr; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+
+	buf, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := `{"addr":"unix://@my-socket"}`, string(buf); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	if err := json.Unmarshal([]byte(`{"addr":"foo://bar"}`), &config); err == nil {
+		t.Errorf("want error, have none")
+	}
+}
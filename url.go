@@ -0,0 +1,208 @@
+package unixtransport
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NewURL returns a URL with the given scheme, e.g. "http+unix", which will
+// address the given request path and query on the Unix socket at the given
+// socket path. The result is understood by transports configured via
+// [Register], and can be decomposed again with [SplitURL], also after a round
+// trip through its string form. A nil query is valid.
+//
+// Any ':' in the socket path is percent-encoded, so the URL is unambiguous even
+// for socket paths like /run/app:v2/http.sock. Socket paths with a leading '@'
+// are in the abstract namespace, and are represented in the URL host, or, for
+// names that start with a '/', in the URL path after an '@'. Relative socket
+// paths are taken as logical socket names, see [SocketResolver], and are also
+// represented in the URL host, like http+unix://myapp:/request/path. Logical
+// names must be a single path element, so e.g. "rel/app.sock" is rejected.
+//
+// NewURL returns an error if the scheme doesn't have a "+unix" suffix, or if
+// the socket path can't be represented in a URL, e.g. an abstract name like
+// @a/b, which contains a '/' but doesn't start with one.
+func NewURL(scheme, socketPath, requestPath string, query url.Values) (*url.URL, error) {
+	if isSocketName(socketPath) {
+		if err := validateSocketName(socketPath); err != nil {
+			return nil, err
+		}
+	}
+
+	u := newURL(scheme, socketPath, requestPath, query)
+
+	parsed, err := url.Parse(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("socket path %q can't be represented in a URL: %w", socketPath, err)
+	}
+
+	uu, err := parseUnixURL(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if uu.socketPath != socketPath {
+		return nil, fmt.Errorf("socket path %q can't be represented in a URL", socketPath)
+	}
+
+	return u, nil
+}
+
+func newURL(scheme, socketPath, requestPath string, query url.Values) *url.URL {
+	switch {
+	case isAbstractAddress(socketPath) && !strings.HasPrefix(socketPath, "@/"):
+		return &url.URL{
+			Scheme:   scheme,
+			User:     url.User(""),
+			Host:     strings.TrimPrefix(socketPath, "@"),
+			Path:     requestPath,
+			RawQuery: query.Encode(),
+		}
+
+	case isSocketName(socketPath):
+		// The trailing ':' marks the host as the socket name even if the
+		// request path contains a ':', see parseUnixURL.
+		return &url.URL{
+			Scheme:   scheme,
+			Host:     socketPath + ":",
+			Path:     requestPath,
+			RawQuery: query.Encode(),
+		}
+	}
+
+	var user *url.Userinfo
+	if isAbstractAddress(socketPath) {
+		user, socketPath = url.User(""), strings.TrimPrefix(socketPath, "@")
+	}
+
+	var (
+		path    = socketPath
+		rawPath = escapeSocketPath(socketPath)
+	)
+	if requestPath != "" {
+		path += ":" + requestPath
+		rawPath += ":" + (&url.URL{Path: requestPath}).EscapedPath()
+	}
+
+	return &url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Path:     path,
+		RawPath:  rawPath,
+		RawQuery: query.Encode(),
+	}
+}
+
+// SplitURL decomposes a URL with a "+unix" scheme, e.g. "http+unix", into the
+// path of the Unix socket it addresses, and the request path on that socket.
+// The request path is decoded, i.e. like [url.URL.Path]. The query and fragment
+// are ignored. For URLs that identify a socket by name, like
+// http+unix://myapp:/request/path, the returned socket path is that name, see
+// [SocketResolver]. SplitURL uses the same rules as transports configured via
+// [Register], and returns an error for URLs those transports would reject.
+func SplitURL(u *url.URL) (socketPath, requestPath string, err error) {
+	uu, err := parseUnixURL(u)
+	if err != nil {
+		return "", "", err
+	}
+	return uu.socketPath, uu.requestPath, nil
+}
+
+// unixURL is the result of parsing a URL with a "+unix" scheme.
+type unixURL struct {
+	scheme         string // without the "+unix" suffix
+	socketPath     string
+	requestPath    string
+	rawRequestPath string // see url.URL.RawPath
+}
+
+// parseUnixURL separates the socket path from the request path in a URL with a
+// "+unix" scheme. Normally, both are in the URL path, separated by the first
+// literal ':', and any ':' in the socket path is percent-encoded. URLs for
+// abstract sockets, and URLs with logical socket names, carry the socket name in
+// the host instead. Abstract names that start with a '/', like @/tmp/my.sock,
+// aren't valid hosts, and use the path form after the '@'.
+//
+// Before logical socket names were supported, the host of a URL was ignored, so
+// e.g. http+unix://localhost/tmp/app.sock:/x addressed /tmp/app.sock. To keep
+// those URLs working, the host is only taken as a logical name if it ends in a
+// ':', or if the path has no socket part, i.e. doesn't contain a ':'. The host
+// can't be ignored whenever the path starts with a '/', because http.NewRequest
+// drops the trailing ':' of a host, turning http+unix://name:/path into
+// http+unix://name/path. So URLs with a host but without a request path, like
+// http+unix://localhost/tmp/app.sock, now address the logical name "localhost",
+// and need to drop the host to address /tmp/app.sock.
+func parseUnixURL(u *url.URL) (unixURL, error) {
+	if u == nil {
+		return unixURL{}, fmt.Errorf("no URL")
+	}
+
+	scheme := strings.TrimSuffix(u.Scheme, "+unix")
+	if scheme == u.Scheme {
+		return unixURL{}, fmt.Errorf("missing '+unix' suffix in scheme %s", u.Scheme)
+	}
+
+	if isAbstractURL(u) && u.Host != "" {
+		return unixURL{
+			scheme:         scheme,
+			socketPath:     "@" + strings.TrimSuffix(u.Host, ":"),
+			requestPath:    u.Path,
+			rawRequestPath: u.RawPath,
+		}, nil
+	}
+
+	if u.Host != "" && (strings.HasSuffix(u.Host, ":") || !strings.Contains(u.EscapedPath(), ":")) {
+		name := strings.TrimSuffix(u.Host, ":")
+		if err := validateSocketName(name); err != nil {
+			return unixURL{}, err
+		}
+		return unixURL{
+			scheme:         scheme,
+			socketPath:     name,
+			requestPath:    u.Path,
+			rawRequestPath: u.RawPath,
+		}, nil
+	}
+
+	rawSocketPath, rawRequestPath, _ := strings.Cut(u.EscapedPath(), ":")
+
+	socketPath, err := url.PathUnescape(rawSocketPath)
+	if err != nil {
+		return unixURL{}, fmt.Errorf("invalid socket path: %w", err)
+	}
+
+	if socketPath == "" {
+		return unixURL{}, fmt.Errorf("empty socket path")
+	}
+
+	if isAbstractURL(u) {
+		socketPath = "@" + socketPath
+	}
+
+	requestPath, err := url.PathUnescape(rawRequestPath)
+	if err != nil {
+		return unixURL{}, fmt.Errorf("invalid request path: %w", err)
+	}
+
+	return unixURL{
+		scheme:         scheme,
+		socketPath:     socketPath,
+		requestPath:    requestPath,
+		rawRequestPath: rawRequestPath,
+	}, nil
+}
+
+// escapeSocketPath percent-encodes the socket path for use in a URL path,
+// including any ':', which would otherwise terminate the socket path.
+func escapeSocketPath(socketPath string) string {
+	escaped := (&url.URL{Path: socketPath}).EscapedPath()
+	return strings.ReplaceAll(escaped, ":", "%3A")
+}
+
+// isAbstractURL returns true if the URL has the form scheme://@name... or
+// scheme://@/path..., which is parsed as an empty userinfo followed by a host,
+// or by an empty host and a path, respectively.
+func isAbstractURL(u *url.URL) bool {
+	return u.User != nil && u.User.String() == ""
+}
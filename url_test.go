@@ -0,0 +1,199 @@
+package unixtransport_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterbourgon/unixtransport"
+)
+
+func TestNewURL(t *testing.T) {
+	t.Parallel()
+
+	for _, testcase := range []struct {
+		scheme, socketPath, requestPath string
+		query                           url.Values
+		want                            string
+	}{
+		{"http+unix", "/tmp/my.sock", "", nil, "http+unix:///tmp/my.sock"},
+		{"http+unix", "/tmp/my.sock", "/", nil, "http+unix:///tmp/my.sock:/"},
+		{"https+unix", "/tmp/my.sock", "/a/b", nil, "https+unix:///tmp/my.sock:/a/b"},
+		{"http+unix", "/run/app:v2/http.sock", "/a:b", nil, "http+unix:///run/app%3Av2/http.sock:/a:b"},
+		{"http+unix", "/tmp/100%/my sock", "/a b", nil, "http+unix:///tmp/100%25/my%20sock:/a%20b"},
+		{"http+unix", "@name", "/a", nil, "http+unix://@name/a"},
+		{"http+unix", "@name", "", nil, "http+unix://@name"},
+		{"http+unix", "myapp", "/a", nil, "http+unix://myapp:/a"},
+		{"http+unix", "myapp", "/a:b", nil, "http+unix://myapp:/a:b"},
+		{"http+unix", "myapp", "", nil, "http+unix://myapp:"},
+		{"http+unix", "/tmp/my.sock", "/a", url.Values{"q": {"1 2"}}, "http+unix:///tmp/my.sock:/a?q=1+2"},
+		{"http+unix", "@name", "/a", url.Values{"q": {"x"}}, "http+unix://@name/a?q=x"},
+		{"http+unix", "@/tmp/x.sock", "/a", nil, "http+unix://@/tmp/x.sock:/a"},
+		{"http+unix", "@/tmp/x.sock", "", nil, "http+unix://@/tmp/x.sock"},
+		{"http+unix", "@/run/app:v2", "/a:b", nil, "http+unix://@/run/app%3Av2:/a:b"},
+	} {
+		t.Run(testcase.want, func(t *testing.T) {
+			u, err := unixtransport.NewURL(testcase.scheme, testcase.socketPath, testcase.requestPath, testcase.query)
+			if err != nil {
+				t.Fatalf("NewURL: %v", err)
+			}
+			if want, have := testcase.want, u.String(); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+
+			// The string form should split into the original socket path.
+			parsed, err := url.Parse(u.String())
+			if err != nil {
+				t.Fatalf("url.Parse: %v", err)
+			}
+			if socketPath, _, err := unixtransport.SplitURL(parsed); err != nil || socketPath != testcase.socketPath {
+				t.Errorf("SplitURL: want %q, have %q (err=%v)", testcase.socketPath, socketPath, err)
+			}
+		})
+	}
+}
+
+func TestNewURLErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, testcase := range []struct {
+		scheme, socketPath string
+	}{
+		{"http", "/tmp/my.sock"},
+		{"http+unix", ""},
+		{"http+unix", "@a/b"},
+		{"http+unix", "@a:b"},
+		{"http+unix", "@"},
+		{"http+unix", "rel/app.sock"},
+		{"http+unix", ".."},
+	} {
+		t.Run(testcase.scheme+" "+testcase.socketPath, func(t *testing.T) {
+			if u, err := unixtransport.NewURL(testcase.scheme, testcase.socketPath, "/a", nil); err == nil {
+				t.Errorf("want error, have %s", u)
+			}
+		})
+	}
+}
+
+func TestSplitURL(t *testing.T) {
+	t.Parallel()
+
+	for _, testcase := range []struct {
+		rawurl                  string
+		socketPath, requestPath string
+		err                     bool
+	}{
+		{rawurl: "http+unix:///tmp/my.sock", socketPath: "/tmp/my.sock", requestPath: ""},
+		{rawurl: "http+unix:///tmp/my.sock:/a/b?c=d#e", socketPath: "/tmp/my.sock", requestPath: "/a/b"},
+		{rawurl: "https+unix:///tmp/my.sock:/a:b:c", socketPath: "/tmp/my.sock", requestPath: "/a:b:c"},
+		{rawurl: "http+unix:///run/app%3Av2/http.sock:/x", socketPath: "/run/app:v2/http.sock", requestPath: "/x"},
+		{rawurl: "http+unix:///tmp/my%20sock:/a%2Fb", socketPath: "/tmp/my sock", requestPath: "/a/b"},
+		{rawurl: "http+unix://@name:/a", socketPath: "@name", requestPath: "/a"},
+		{rawurl: "http+unix://@name/a", socketPath: "@name", requestPath: "/a"},
+		{rawurl: "http+unix://@name", socketPath: "@name", requestPath: ""},
+		{rawurl: "http+unix://@/tmp/my.sock:/a", socketPath: "@/tmp/my.sock", requestPath: "/a"},
+		{rawurl: "http+unix://@/tmp/my%3Asock", socketPath: "@/tmp/my:sock", requestPath: ""},
+		{rawurl: "http+unix://@", err: true},
+		{rawurl: "http+unix://myapp:/a", socketPath: "myapp", requestPath: "/a"},
+		{rawurl: "http+unix://myapp", socketPath: "myapp", requestPath: ""},
+		{rawurl: "http+unix://myapp/a", socketPath: "myapp", requestPath: "/a"},
+		{rawurl: "http+unix://myapp:/a:b", socketPath: "myapp", requestPath: "/a:b"},
+		{rawurl: "http+unix://localhost/tmp/app.sock:/x", socketPath: "/tmp/app.sock", requestPath: "/x"},
+		{rawurl: "http+unix://localhost/tmp/app.sock", socketPath: "localhost", requestPath: "/tmp/app.sock"},
+		{rawurl: "http+unix://..:/a", err: true},
+		{rawurl: "http:///tmp/my.sock:/a", err: true},
+		{rawurl: "unix:///tmp/my.sock", err: true},
+		{rawurl: "http+unix://", err: true},
+	} {
+		t.Run(testcase.rawurl, func(t *testing.T) {
+			u, err := url.Parse(testcase.rawurl)
+			if err != nil {
+				t.Fatalf("url.Parse: %v", err)
+			}
+
+			socketPath, requestPath, err := unixtransport.SplitURL(u)
+			switch {
+			case testcase.err && err == nil:
+				t.Fatalf("want error, have none (socket path %q, request path %q)", socketPath, requestPath)
+			case !testcase.err && err != nil:
+				t.Fatalf("want no error, have %v", err)
+			}
+
+			if want, have := testcase.socketPath, socketPath; want != have {
+				t.Errorf("socket path: want %q, have %q", want, have)
+			}
+			if want, have := testcase.requestPath, requestPath; want != have {
+				t.Errorf("request path: want %q, have %q", want, have)
+			}
+
+			if testcase.err {
+				return
+			}
+
+			// NewURL should produce a URL that splits the same way, also after a
+			// round trip through its string form.
+			rebuilt, err := url.Parse(newURL(t, u.Scheme, socketPath, requestPath))
+			if err != nil {
+				t.Fatalf("url.Parse: %v", err)
+			}
+			if s, r, err := unixtransport.SplitURL(rebuilt); err != nil || s != socketPath || r != requestPath {
+				t.Errorf("NewURL %s: want %q %q, have %q %q (err=%v)", rebuilt, socketPath, requestPath, s, r, err)
+			}
+		})
+	}
+}
+
+func TestColonSocketPath(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "app:v2")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "http.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, r.URL.EscapedPath()) })
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = ln
+	server.Start()
+	t.Cleanup(func() { server.Close() })
+
+	transport := &http.Transport{}
+	unixtransport.Register(transport)
+	client := &http.Client{Transport: transport}
+
+	for _, testcase := range []struct {
+		rawurl string
+		want   string
+	}{
+		{newURL(t, "http+unix", socket, "/foo"), "/foo"},
+		{newURL(t, "http+unix", socket, "/foo:bar"), "/foo:bar"},
+		{newURL(t, "http+unix", socket, ""), "/"},
+		{"http+unix://" + filepath.Dir(dir) + "/app%3Av2/http.sock:/a%2Fb", "/a%2Fb"},
+	} {
+		if want, have := testcase.want, get(t, client, testcase.rawurl); want != have {
+			t.Errorf("%s: want %q, have %q", testcase.rawurl, want, have)
+		}
+	}
+}
+
+// newURL calls [unixtransport.NewURL] and returns the string form of the URL.
+func newURL(t *testing.T, scheme, socketPath, requestPath string) string {
+	t.Helper()
+	u, err := unixtransport.NewURL(scheme, socketPath, requestPath, nil)
+	if err != nil {
+		t.Fatalf("NewURL: %v", err)
+	}
+	return u.String()
+}